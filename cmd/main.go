@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"time"
 
 	conduit "realworld.tayler.io/internal/api"
 )
@@ -15,19 +17,56 @@ func main() {
 			TimeoutSeconds int
 		}{
 			Driver:         "sqlite3",
-			Dsn:            "file:conduit.db?mode=rwc&cache=shared",
+			Dsn:            "file:conduit.db?mode=rwc&cache=shared&_foreign_keys=on",
 			TimeoutSeconds: 30,
 		},
 		JWT: struct{ SecretKey []byte }{
 			SecretKey: []byte("secret-key"),
 		},
 	}
-	app, closeDb, err := conduit.NewApp(config)
+	config.Accounts.DeletionGracePeriod = 30 * 24 * time.Hour
+	config.Accounts.PurgeInterval = time.Hour
+	config.Export.AsyncThreshold = 500
+	config.Export.LinkTTL = 24 * time.Hour
+	config.Audit.RetentionPeriod = 365 * 24 * time.Hour
+	config.Audit.PruneInterval = 24 * time.Hour
+	config.CORS.AllowedOrigins = []string{"http://localhost:3000", "http://localhost:4100"}
+	config.CORS.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	config.CORS.AllowedHeaders = []string{"Authorization", "Content-Type", "X-Request-Id", "Idempotency-Key"}
+	config.CORS.ExposedHeaders = []string{"X-Request-Id", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Idempotent-Replayed"}
+	config.CORS.MaxAge = 10 * time.Minute
+	config.Pagination.CountEstimateLimit = 1000
+	config.Cache.PublicMaxAge = time.Minute
+	config.Compression.Enabled = true
+	config.Compression.MinSize = 1024
+	config.RateLimit.Enabled = true
+	config.RateLimit.Default = conduit.RateLimit{Rate: 10, Burst: 40}
+	config.RateLimit.Routes = map[string]conduit.RateLimit{
+		"POST /api/users/login":   {Rate: 10.0 / 60, Burst: 20},
+		"POST /api/users":         {Rate: 10.0 / 60, Burst: 20},
+		"POST /api/users/restore": {Rate: 10.0 / 60, Burst: 20},
+		"POST /api/articles":      {Rate: 30.0 / 60, Burst: 30},
+	}
+	config.RateLimit.IdleTimeout = 10 * time.Minute
+	config.OpenAPI.SwaggerUI = true
+	config.OpenAPI.ValidateRequests = true
+	config.Idempotency.TTL = 24 * time.Hour
+	config.Idempotency.CleanupInterval = time.Hour
+	config.Publishing.Interval = time.Minute
+	config.Trending.Window = 7 * 24 * time.Hour
+	config.Trending.HalfLife = 24 * time.Hour
+	config.Trending.Interval = 10 * time.Minute
+	config.Markdown.CacheSize = 1000
+	config.Related.CacheSize = 1000
+	config.Related.CacheTTL = 10 * time.Minute
+
+	app, cleanup, err := conduit.NewApp(config)
 	if err != nil {
-		fmt.Printf("Error starting the application: %v", err.Error())
+		fmt.Printf("Error starting the application: %v\n", err.Error())
+		os.Exit(1)
 	}
 
-	defer closeDb()
+	defer cleanup()
 
 	http.ListenAndServe(":4000", app.Routes())
 }
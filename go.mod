@@ -7,6 +7,19 @@ require github.com/justinas/alice v1.2.0
 require (
 	github.com/mattn/go-sqlite3 v1.14.24
 	golang.org/x/crypto v0.32.0
+	golang.org/x/text v0.21.0
 )
 
-require github.com/golang-jwt/jwt/v5 v5.2.1
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/klauspost/compress v1.17.11
+	github.com/microcosm-cc/bluemonday v1.0.27
+	github.com/yuin/goldmark v1.7.8
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+)
@@ -1,7 +1,9 @@
 package conduit
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -9,7 +11,10 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,9 +23,20 @@ import (
 )
 
 type Application struct {
-	logger       *slog.Logger
-	domains      domains
-	tokenService data.ITokenService
+	logger           *slog.Logger
+	config           Config
+	domains          domains
+	tokenService     data.ITokenService
+	jobs             jobs
+	exports          exports
+	rateLimiter      rateLimiter
+	idempotencyLocks keyedMutex
+	renders          renderCache
+	related          relatedCache
+	openAPI          *openAPIDocument
+	trustedProxies   []netip.Prefix
+	// keys the hashes that anonymous viewers of articles are told apart by, made afresh on start
+	viewerKey []byte
 }
 
 type Config struct {
@@ -32,17 +48,119 @@ type Config struct {
 	JWT struct {
 		SecretKey []byte
 	}
+	Accounts struct {
+		// how long a deleted account can still be restored before it is purged,
+		// zero means accounts are purged as soon as deletion is requested
+		DeletionGracePeriod time.Duration
+		PurgeInterval       time.Duration
+	}
+	Export struct {
+		// directory that exports generated in the background are written to, defaults to the OS temp dir
+		Dir string
+		// exports covering more articles, comments and favorites than this are generated in the background
+		AsyncThreshold int
+		// how long the signed download link for a background export remains valid
+		LinkTTL time.Duration
+	}
+	Audit struct {
+		// how long audit events are kept for, zero means they are kept forever
+		RetentionPeriod time.Duration
+		PruneInterval   time.Duration
+	}
+	CORS struct {
+		// exact origins such as "https://conduit.example.com", wildcard subdomains
+		// such as "https://*.example.com", or "*" to allow any origin
+		AllowedOrigins   []string
+		AllowedMethods   []string
+		AllowedHeaders   []string
+		ExposedHeaders   []string
+		AllowCredentials bool
+		MaxAge           time.Duration
+	}
+	Concurrency struct {
+		// reject updates that don't say which version of the resource they are based on
+		RequireIfMatch bool
+	}
+	Pagination struct {
+		// when a client asks for an estimated count, counting stops at this many articles
+		CountEstimateLimit int
+	}
+	Cache struct {
+		// how long shared caches and anonymous clients may reuse a cacheable response
+		PublicMaxAge time.Duration
+	}
+	Compression struct {
+		Enabled bool
+		// responses smaller than this many bytes are not worth compressing
+		MinSize int
+	}
+	RateLimit struct {
+		Enabled bool
+		// applied to every route without an entry in Routes
+		Default RateLimit
+		// keyed on the route pattern as registered, e.g. "POST /api/users/login"
+		Routes map[string]RateLimit
+		// addresses or CIDR ranges of proxies whose X-Forwarded-For header can be trusted
+		TrustedProxies []string
+		// how long a client has to be idle before their buckets are forgotten
+		IdleTimeout time.Duration
+	}
+	OpenAPI struct {
+		// serve Swagger UI at /api/docs
+		SwaggerUI bool
+		// reject request bodies that don't match the schema in openapi.json
+		ValidateRequests bool
+	}
+	Idempotency struct {
+		// how long the response to a request with an Idempotency-Key is kept to be replayed
+		TTL             time.Duration
+		CleanupInterval time.Duration
+	}
+	Publishing struct {
+		// how often scheduled articles that are due get published
+		Interval time.Duration
+	}
+	Trending struct {
+		// how far back views, favorites and comments count towards an article trending
+		Window time.Duration
+		// how long it takes for an interaction to count for half as much
+		HalfLife time.Duration
+		// how often the scores are recomputed
+		Interval time.Duration
+	}
+	Markdown struct {
+		// how many articles' rendered bodies are kept, zero turns the cache off
+		CacheSize int
+	}
+	Related struct {
+		// how many articles' related articles are kept, zero turns the cache off
+		CacheSize int
+		// how long related articles are kept before they are ranked again, see relatedCache
+		CacheTTL time.Duration
+	}
+}
+
+// RateLimit allows a burst of requests at once, after which requests are allowed at a steady rate
+type RateLimit struct {
+	// requests per second
+	Rate float64
+	// the most requests that can be made at once
+	Burst int
 }
 
 type domains struct {
-	users    data.UserRepository
-	articles data.ArticleRepository
-	comments data.CommentRepository
-	tags     data.TagRepository
+	users       data.UserRepository
+	articles    data.ArticleRepository
+	comments    data.CommentRepository
+	tags        data.TagRepository
+	audit       data.AuditRepository
+	idempotency data.IdempotencyRepository
 }
 
 type envelope map[string]any
 
+// NewApp wires up the application and starts its background jobs. The returned cleanup
+// function stops the jobs and closes the database, and should be deferred by the caller.
 func NewApp(config Config) (*Application, func(), error) {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		AddSource: true,
@@ -51,6 +169,21 @@ func NewApp(config Config) (*Application, func(), error) {
 
 	logger.Info("test logging works")
 
+	trustedProxies, err := parseTrustedProxies(config.RateLimit.TrustedProxies)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	openAPI, err := parseOpenAPISpec()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	viewerKey := make([]byte, 32)
+	if _, err := rand.Read(viewerKey); err != nil {
+		return nil, nil, err
+	}
+
 	db, closeDb, err := OpenDB(config, logger)
 	if err != nil {
 		return nil, nil, err
@@ -58,10 +191,12 @@ func NewApp(config Config) (*Application, func(), error) {
 
 	app := &Application{
 		logger: logger,
+		config: config,
 		domains: domains{
 			users: data.UserRepository{
 				DB:             db,
 				TimeoutSeconds: config.DB.TimeoutSeconds,
+				Log:            logger,
 			},
 			articles: data.ArticleRepository{
 				DB:             db,
@@ -76,13 +211,56 @@ func NewApp(config Config) (*Application, func(), error) {
 				DB:             db,
 				TimeoutSeconds: config.DB.TimeoutSeconds,
 			},
+			audit: data.AuditRepository{
+				DB:             db,
+				TimeoutSeconds: config.DB.TimeoutSeconds,
+			},
+			idempotency: data.IdempotencyRepository{
+				DB:             db,
+				TimeoutSeconds: config.DB.TimeoutSeconds,
+			},
 		},
 		tokenService: data.JwtTokenService{
 			SecretKey: config.JWT.SecretKey,
 		},
+		exports: exports{
+			jobs: make(map[string]*exportJob),
+		},
+		rateLimiter: rateLimiter{
+			buckets: make(map[string]*bucket),
+		},
+		idempotencyLocks: keyedMutex{
+			locks: make(map[string]*keyedLock),
+		},
+		renders: renderCache{
+			entries: make(map[int]renderedArticle),
+		},
+		related: relatedCache{
+			entries: make(map[relatedKey]relatedEntry),
+		},
+		viewerKey:      viewerKey,
+		openAPI:        openAPI,
+		trustedProxies: trustedProxies,
 	}
 
-	return app, closeDb, nil
+	// articles saved before reading stats were stored get them before any are served
+	filled, err := app.domains.articles.BackfillReadingStats()
+	if err != nil {
+		closeDb()
+		return nil, nil, err
+	}
+	if filled > 0 {
+		logger.Info("backfilled reading stats", "articles", filled)
+	}
+
+	app.startJobs()
+
+	cleanup := func() {
+		app.stopJobs()
+		closeDb()
+	}
+
+	return app, cleanup, nil
 }
 
 func OpenDB(config Config, logger *slog.Logger) (*sql.DB, func(), error) {
@@ -106,6 +284,17 @@ func OpenDB(config Config, logger *slog.Logger) (*sql.DB, func(), error) {
 		return nil, nil, err
 	}
 
+	// article search needs FTS5, which go-sqlite3 only compiles in with the sqlite_fts5 build tag.
+	// Without it the build succeeds but every search fails, so refuse to start instead.
+	var fts5 bool
+	err = db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !fts5 {
+		return nil, nil, errors.New("sqlite was built without FTS5, which article search needs: build with -tags sqlite_fts5")
+	}
+
 	closeDb := func() {
 		if err = db.Close(); err != nil {
 			logger.Error(err.Error())
@@ -116,10 +305,15 @@ func OpenDB(config Config, logger *slog.Logger) (*sql.DB, func(), error) {
 }
 
 func (app *Application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	// Use the json.MarshalIndent() function so that whitespace is added to the encoded
-	// JSON. Here we use no line prefix ("") and tab indents ("\t") for each element.
-	// There's a small perf hit for this, but it's neglible.
-	js, err := json.MarshalIndent(data, "", "\t")
+	// JSON is compact unless the client asked for it to be indented (see negotiateResponse),
+	// indenting with tabs bloats large responses considerably, even once compressed.
+	var js []byte
+	var err error
+	if jsonIndented(w) {
+		js, err = json.MarshalIndent(data, "", "\t")
+	} else {
+		js, err = json.Marshal(data)
+	}
 	if err != nil {
 		return err
 	}
@@ -138,6 +332,74 @@ func (app *Application) writeJSON(w http.ResponseWriter, status int, data envelo
 	return nil
 }
 
+// writeJSONList writes an envelope holding a list under key, along with any other values in data.
+// Rather than encoding the whole response up front, the list is streamed out an element at a time,
+// so large lists don't have to be held in memory twice. items must be a slice. An error is only
+// returned before the status is written, while an error response can still be sent instead.
+func (app *Application) writeJSONList(w http.ResponseWriter, status int, key string, items any, data envelope, headers http.Header) error {
+	if jsonIndented(w) {
+		env := envelope{key: items}
+		for k, v := range data {
+			env[k] = v
+		}
+		return app.writeJSON(w, status, env, headers)
+	}
+
+	// encode everything but the list first, so that a failure there can still become a 500
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var head bytes.Buffer
+	head.WriteByte('{')
+	for _, k := range keys {
+		js, err := json.Marshal(data[k])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&head, "%q:%s,", k, js)
+	}
+	fmt.Fprintf(&head, "%q:[", key)
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	// the status has been sent, so a failure from here on can't become a 500. The body is left
+	// cut short, which the client will see as invalid JSON, and nil is returned so that the
+	// caller doesn't try to write an error response over the top of it.
+	if _, err := w.Write(head.Bytes()); err != nil {
+		app.logger.Error("failed to write list response", slog.String("error", err.Error()))
+		return nil
+	}
+
+	list := reflect.ValueOf(items)
+	for i := 0; i < list.Len(); i++ {
+		js, err := json.Marshal(list.Index(i).Interface())
+		if err != nil {
+			app.logger.Error("failed to encode list item", slog.String("key", key), slog.Int("index", i), slog.String("error", err.Error()))
+			return nil
+		}
+		if i > 0 {
+			js = append([]byte{','}, js...)
+		}
+		if _, err = w.Write(js); err != nil {
+			app.logger.Error("failed to write list response", slog.String("error", err.Error()))
+			return nil
+		}
+	}
+
+	if _, err := w.Write([]byte("]}\n")); err != nil {
+		app.logger.Error("failed to write list response", slog.String("error", err.Error()))
+	}
+	return nil
+}
+
 func (app *Application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	/*
 	* the "json" package in Go has some flaws and a v2 is in discussion here:
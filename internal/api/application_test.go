@@ -0,0 +1,123 @@
+package conduit
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"realworld.tayler.io/internal/data"
+)
+
+// newTestApplication returns an application backed by a fresh database with every migration
+// applied, and no background jobs. Tests using it are skipped unless built with -tags sqlite_fts5,
+// since the migrations need FTS5.
+func newTestApplication(t *testing.T) *Application {
+	t.Helper()
+
+	// a file rather than :memory:, as some queries run alongside a transaction on another connection
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "conduit.db")+"?mode=rwc&_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var fts5 bool
+	if err = db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		t.Fatal(err)
+	}
+	if !fts5 {
+		t.Skip("sqlite was built without FTS5: run with -tags sqlite_fts5")
+	}
+
+	migrations, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range migrations {
+		migration, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	app := &Application{
+		logger: logger,
+		domains: domains{
+			users:       data.UserRepository{DB: db, TimeoutSeconds: 5, Log: logger},
+			articles:    data.ArticleRepository{DB: db, TimeoutSeconds: 5, Log: logger},
+			comments:    data.CommentRepository{DB: db, TimeoutSeconds: 5},
+			tags:        data.TagRepository{DB: db, TimeoutSeconds: 5},
+			audit:       data.AuditRepository{DB: db, TimeoutSeconds: 5},
+			idempotency: data.IdempotencyRepository{DB: db, TimeoutSeconds: 5},
+		},
+		renders: renderCache{
+			entries: make(map[int]renderedArticle),
+		},
+		related: relatedCache{
+			entries: make(map[relatedKey]relatedEntry),
+		},
+	}
+	app.config.Markdown.CacheSize = 10
+
+	return app
+}
+
+// serveAs calls handler with the request as if it were made by the user, with userId 0 for anonymous requests
+func serveAs(handler http.HandlerFunc, r *http.Request, userId int) *httptest.ResponseRecorder {
+	r = r.WithContext(context.WithValue(r.Context(), userContextKey, &userContext{isAuthenticated: userId != 0, userId: userId}))
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// insertUser adds a user with the username, returning their id
+func insertUser(t *testing.T, app *Application, username string) int {
+	t.Helper()
+
+	result, err := app.domains.users.DB.Exec(`INSERT INTO User (Email, PasswordHash, Username, Bio) VALUES ($1, '', $2, '')`, username+"@example.com", username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// jsonRequest is a request with the body, which is JSON with its single quotes swapped for double quotes
+func jsonRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(strings.ReplaceAll(body, "'", `"`)))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestWriteJSONListEncodeError(t *testing.T) {
+	app := &Application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	// a channel can't be encoded, but the status has already been sent by the time it is reached
+	w := httptest.NewRecorder()
+	err := app.writeJSONList(w, http.StatusOK, "items", []any{1, make(chan int)}, nil, nil)
+	if err != nil {
+		t.Fatalf("got error %v once the status was written, want nil", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if got, want := w.Body.String(), `{"items":[1`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
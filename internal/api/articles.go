@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"realworld.tayler.io/internal/data"
 	"realworld.tayler.io/internal/validator"
@@ -19,27 +21,79 @@ func (app *Application) getArticlesHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	articles, err := app.domains.articles.GetArticles(filters, app.getUserContext(r).userId)
+	articles, cursors, err := app.domains.articles.GetArticles(filters, app.getUserContext(r).userId)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			articles = make([]*data.BodylessArticle, 0)
+			cursors = &data.PageCursors{}
 		default:
 			app.serveResponseErrorInternalServerError(w, err)
 			return
 		}
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"articles": articles, "articlesCount": len(articles)}, nil)
+	countLimit := app.articleCountLimit(&filters.PaginationFilters)
+	count, err := app.domains.articles.CountArticles(filters, countLimit)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSONList(w, http.StatusOK, "articles", articles, articleListEnvelope(count, countLimit, cursors), nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// GET /api/articles/search
+func (app *Application) searchArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := &data.SearchFilters{}
+
+	if filters.ParseFilters(v, r); !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	results, err := app.domains.articles.SearchArticles(filters, app.getUserContext(r).userId)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	countLimit := app.articleCountLimit(&filters.PaginationFilters)
+	count, err := app.domains.articles.CountSearch(filters, countLimit)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSONList(w, http.StatusOK, "articles", results, articleListEnvelope(count, countLimit, &data.PageCursors{}), nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
 	}
 }
 
 // GET /api/articles/:slug
+//
+// With ?html=true the body is also rendered to sanitized HTML, along with a table of contents
 func (app *Application) getArticleHandler(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
 
+	v := validator.New()
+	var html bool
+	if r.URL.Query().Has("html") {
+		var err error
+		html, err = strconv.ParseBool(r.URL.Query().Get("html"))
+		v.Check(err == nil, "html", "must be true or false")
+	}
+
+	if !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
 	article, err := app.domains.articles.GetArticleBySlug(slug, app.getUserContext(r).userId)
 	if err != nil {
 		switch {
@@ -51,7 +105,82 @@ func (app *Application) getArticleHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"article": article}, nil)
+	// the slug is an old one, so point the client at the article's current URL
+	if article.Slug != slug {
+		headers := make(http.Header)
+		headers.Set("Location", "/api/articles/"+url.PathEscape(article.Slug))
+
+		err = app.writeJSON(w, http.StatusMovedPermanently, envelope{"canonicalSlug": article.Slug}, headers)
+		if err != nil {
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	app.recordArticleView(r, article)
+
+	if app.serveNotModified(w, r, articleETag(article), article.ModifiedAt) {
+		return
+	}
+
+	if !html {
+		err = app.writeJSON(w, http.StatusOK, envelope{"article": article}, nil)
+		if err != nil {
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	rendered, err := app.renderArticleBody(article)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"article": renderedArticleResponse{
+		Article:         article,
+		BodyHTML:        rendered.HTML,
+		TableOfContents: rendered.TableOfContents,
+	}}, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// renderedArticleResponse is an article along with its body rendered to HTML
+type renderedArticleResponse struct {
+	*data.Article
+	BodyHTML        string         `json:"bodyHtml"`
+	TableOfContents []data.Heading `json:"tableOfContents"`
+}
+
+// GET /api/user/articles
+func (app *Application) getUserArticlesHandler(w http.ResponseWriter, r *http.Request) {
+
+	v := validator.New()
+	filters := &data.UserArticleFilters{}
+
+	if filters.ParseFilters(v, r); !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	userId := app.getUserContext(r).userId
+
+	articles, cursors, err := app.domains.articles.GetUserArticles(filters, userId)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	countLimit := app.articleCountLimit(&filters.PaginationFilters)
+	count, err := app.domains.articles.CountUserArticles(filters, userId, countLimit)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSONList(w, http.StatusOK, "articles", articles, articleListEnvelope(count, countLimit, cursors), nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
 	}
@@ -68,16 +197,46 @@ func (app *Application) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	articles, err := app.domains.articles.GetFeed(filters, app.getUserContext(r).userId)
+	articles, cursors, err := app.domains.articles.GetFeed(filters, app.getUserContext(r).userId)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"articles": articles, "articlesCount": len(articles)}, nil)
+	countLimit := app.articleCountLimit(filters)
+	count, err := app.domains.articles.CountFeed(app.getUserContext(r).userId, countLimit)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSONList(w, http.StatusOK, "articles", articles, articleListEnvelope(count, countLimit, cursors), nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// articleCountLimit is how far to count matching articles, zero meaning all of them
+func (app *Application) articleCountLimit(filters *data.PaginationFilters) int {
+	if filters.EstimateCount {
+		return app.config.Pagination.CountEstimateLimit
+	}
+	return 0
+}
+
+// articleListEnvelope holds everything in a list of articles apart from the articles themselves.
+// An estimated count that reached the limit is only a lower bound, which is flagged for clients
+// so they can show something like "1000+".
+func articleListEnvelope(count, countLimit int, cursors *data.PageCursors) envelope {
+	env := envelope{
+		"articlesCount": count,
+		"nextCursor":    cursors.Next,
+		"prevCursor":    cursors.Prev,
+	}
+	if countLimit > 0 {
+		env["articlesCountEstimated"] = count >= countLimit
 	}
+	return env
 }
 
 // POST /api/articles
@@ -109,6 +268,8 @@ func (app *Application) createArticleHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	app.related.invalidate()
+
 	err = app.writeJSON(w, http.StatusCreated, envelope{"article": article}, nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
@@ -136,6 +297,16 @@ func (app *Application) updateArticleHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && app.config.Concurrency.RequireIfMatch {
+		app.serveResponseErrorPreconditionRequired(w, r)
+		return
+	}
+	if ifMatch != "" && !ifMatchVersion(ifMatch, article.Version) {
+		app.serveResponseErrorPreconditionFailed(w, r, articleETag(article), envelope{"article": article})
+		return
+	}
+
 	var input data.UpdateArticleDTO
 	err = app.readJSON(w, r, &input)
 	if err != nil {
@@ -159,20 +330,41 @@ func (app *Application) updateArticleHandler(w http.ResponseWriter, r *http.Requ
 	if input.Article.Description == nil {
 		input.Article.Description = &article.Description
 	}
+	switch {
+	case input.Article.Status == nil && input.Article.PublishAt == nil:
+		input.Article.Status = &article.Status
+		input.Article.PublishAt = article.PublishAt
+	case input.Article.Status != nil && *input.Article.Status == data.ArticleStatusPublished && article.Status == data.ArticleStatusPublished:
+		// republishing doesn't change when the article was first published
+		input.Article.PublishAt = article.PublishAt
+	}
 
-	article, err = app.domains.articles.UpdateArticle(input, article.ArticleId, currentUserId)
+	updated, err := app.domains.articles.UpdateArticle(input, article.ArticleId, currentUserId, article.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateSlug):
 			v.AddError("slug", "duplicate slug")
 			app.serveResponseErrorUnprocessableEntity(w, v)
+		case errors.Is(err, data.ErrEditConflict):
+			// someone else got in between us reading the article and writing it back
+			current, err := app.domains.articles.GetArticleBySlug(slug, currentUserId)
+			if err != nil {
+				app.serveResponseErrorInternalServerError(w, err)
+				return
+			}
+			app.serveResponseErrorPreconditionFailed(w, r, articleETag(current), envelope{"article": current})
 		default:
 			app.serveResponseErrorInternalServerError(w, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"article": article}, nil)
+	app.related.invalidate()
+
+	headers := make(http.Header)
+	headers.Set("ETag", articleETag(updated))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"article": updated}, headers)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
 	}
@@ -206,6 +398,10 @@ func (app *Application) deleteArticleHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	app.related.invalidate()
+	app.renders.evict(article.ArticleId)
+	app.audit(r, data.AuditArticleDeleted, "article", article.Slug, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -230,6 +426,7 @@ func (app *Application) favoriteArticleHandler(w http.ResponseWriter, r *http.Re
 			app.serveResponseErrorInternalServerError(w, err)
 			return
 		}
+		app.related.invalidateArticle(article.ArticleId)
 
 		article, err = app.domains.articles.GetArticleBySlug(slug, app.getUserContext(r).userId)
 		if err != nil {
@@ -265,6 +462,7 @@ func (app *Application) unfavoriteArticleHandler(w http.ResponseWriter, r *http.
 			app.serveResponseErrorInternalServerError(w, err)
 			return
 		}
+		app.related.invalidateArticle(article.ArticleId)
 
 		article, err = app.domains.articles.GetArticleBySlug(slug, app.getUserContext(r).userId)
 		if err != nil {
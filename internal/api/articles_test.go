@@ -0,0 +1,167 @@
+package conduit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetArticlesDateFilters(t *testing.T) {
+	app := newTestApplication(t)
+	author := insertUser(t, app, "author")
+
+	r := jsonRequest(http.MethodPost, "/api/articles", `{'article':{'title':'Dragons','description':'Ever wonder how?','body':'Carefully.'}}`)
+	if w := serveAs(app.createArticleHandler, r, author); w.Code != http.StatusCreated {
+		t.Fatalf("got status %d creating an article: %s", w.Code, w.Body)
+	}
+	// on a whole second, which is written without any fractional seconds
+	_, err := app.domains.articles.DB.Exec(`UPDATE Article SET CreatedAt = '2024-01-02T15:04:05Z', UpdatedAt = '2024-01-02T15:04:05Z'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"createdAfter=2024-01-02T15:04:04.9Z", 1},
+		{"createdAfter=2024-01-02T15:04:05.1Z", 0},
+		{"createdAfter=2024-01-02T15:04:05Z", 0},
+		{"createdBefore=2024-01-02T15:04:05.1Z", 1},
+		{"createdBefore=2024-01-02T15:04:04.9Z", 0},
+		{"createdBefore=2024-01-02T16:04:05.1%2B01:00", 1},
+		{"updatedSince=2024-01-02T15:04:05Z", 1},
+		{"updatedSince=2024-01-02T15:04:05.1Z", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serveAs(app.getArticlesHandler, jsonRequest(http.MethodGet, "/api/articles?"+tt.query, ""), 0)
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", w.Code, w.Body)
+			}
+
+			var response struct {
+				Articles []json.RawMessage `json:"articles"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Articles) != tt.want {
+				t.Errorf("got %d articles, want %d", len(response.Articles), tt.want)
+			}
+		})
+	}
+}
+
+func TestGetArticlesOrder(t *testing.T) {
+	app := newTestApplication(t)
+	author := insertUser(t, app, "author")
+
+	create := func(title, extra string) int {
+		t.Helper()
+		r := jsonRequest(http.MethodPost, "/api/articles", `{'article':{'title':'`+title+`','description':'Ever wonder how?','body':'Carefully.'`+extra+`}}`)
+		if w := serveAs(app.createArticleHandler, r, author); w.Code != http.StatusCreated {
+			t.Fatalf("got status %d creating an article: %s", w.Code, w.Body)
+		}
+		article, err := app.domains.articles.GetArticleBySlug(title, author)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		return article.ArticleId
+	}
+
+	list := func(query string) ([]string, string) {
+		t.Helper()
+		w := serveAs(app.getArticlesHandler, jsonRequest(http.MethodGet, "/api/articles?"+query, ""), 0)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+		var response struct {
+			Articles []struct {
+				Slug string `json:"slug"`
+			} `json:"articles"`
+			NextCursor *string `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		slugs := make([]string, 0, len(response.Articles))
+		for _, article := range response.Articles {
+			slugs = append(slugs, article.Slug)
+		}
+		next := ""
+		if response.NextCursor != nil {
+			next = *response.NextCursor
+		}
+		return slugs, next
+	}
+
+	first := create("first", "")
+	scheduled := create("scheduled", `,'publishAt':'`+time.Now().Add(time.Hour).Format(time.RFC3339)+`'`)
+	last := create("last", "")
+
+	// the scheduled article is published after the last one was
+	_, err := app.domains.articles.DB.Exec(`UPDATE Article SET PublishAt = $1 WHERE ArticleId = $2`, time.Now().UTC().Format(time.RFC3339Nano), scheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = app.domains.articles.PublishScheduledArticles(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("newest by when they were published", func(t *testing.T) {
+		if got, _ := list("order=newest"); !slices.Equal(got, []string{"scheduled", "last", "first"}) {
+			t.Errorf("got %v", got)
+		}
+		if got, _ := list("order=oldest"); !slices.Equal(got, []string{"first", "last", "scheduled"}) {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("paging through favorites made while paging", func(t *testing.T) {
+		favorite := func(articleId, n int) {
+			t.Helper()
+			for i := 0; i < n; i++ {
+				userId := insertUser(t, app, fmt.Sprintf("fan-%d-%d", articleId, i))
+				if err := app.domains.articles.FavoriteArticle(articleId, userId); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		favorite(first, 2)
+		favorite(scheduled, 1)
+
+		page, next := list("order=favorited&limit=1")
+		seen := page
+		// enough to put the last article ahead of the first had it been there all along
+		favorite(last, 3)
+		for next != "" {
+			page, next = list("order=favorited&limit=1&cursor=" + next)
+			seen = append(seen, page...)
+		}
+
+		if !slices.Equal(seen, []string{"first", "scheduled", "last"}) {
+			t.Errorf("got %v, want every article once", seen)
+		}
+	})
+
+	t.Run("tampered cursors", func(t *testing.T) {
+		for _, raw := range []string{
+			`{"o":"updated","k":"2024-01-02T03:04:05Z","i":1}`,
+			`{"o":"favorited","k":1.5,"i":1,"t":"2024-01-02T03:04:05Z"}`,
+			`{"o":"favorited","k":1,"i":1,"t":"yesterday"}`,
+		} {
+			cursor := base64.RawURLEncoding.EncodeToString([]byte(raw))
+			w := serveAs(app.getArticlesHandler, jsonRequest(http.MethodGet, "/api/articles?cursor="+cursor, ""), 0)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("got status %d for cursor %s, want %d", w.Code, raw, http.StatusUnprocessableEntity)
+			}
+		}
+	})
+}
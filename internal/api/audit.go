@@ -0,0 +1,64 @@
+package conduit
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+	"realworld.tayler.io/internal/validator"
+)
+
+// audit records a security-sensitive event against the current request. The actor is the
+// authenticated user unless one is passed in, which is needed for requests such as logging in
+// where nobody is authenticated yet. Failing to write the audit log is logged rather than
+// failing the request, since the action being audited has already happened by this point.
+func (app *Application) audit(r *http.Request, action, targetType, targetId string, actor *data.User) {
+	event := data.AuditEvent{
+		Action:    action,
+		Ip:        app.clientIp(r),
+		UserAgent: r.UserAgent(),
+		RequestId: app.getRequestId(r),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if actor != nil {
+		event.ActorUserId = &actor.UserId
+		event.ActorUsername = &actor.Username
+	} else if userContext := app.getUserContext(r); userContext.isAuthenticated {
+		event.ActorUserId = &userContext.userId
+		event.ActorUsername = &userContext.username
+	}
+
+	if targetType != "" {
+		event.TargetType = &targetType
+		event.TargetId = &targetId
+	}
+
+	err := app.domains.audit.InsertEvent(&event)
+	if err != nil {
+		app.logger.Error(err.Error(), slog.String("action", action), slog.String("requestId", event.RequestId))
+	}
+}
+
+// GET /api/admin/audit
+func (app *Application) getAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := &data.AuditFilters{}
+
+	if filters.ParseFilters(v, r); !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	events, nextCursor, err := app.domains.audit.GetEvents(filters)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"auditEvents": events, "nextCursor": nextCursor}, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
@@ -0,0 +1,83 @@
+package conduit
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+)
+
+func TestGetAuditEvents(t *testing.T) {
+	app := newTestApplication(t)
+
+	renamed := insertUser(t, app, "renamed")
+	other := insertUser(t, app, "other")
+
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for _, event := range []struct {
+		userId   int
+		username string
+		at       time.Time
+	}{
+		{renamed, "before", at},
+		{renamed, "renamed", at.Add(500 * time.Millisecond)},
+		// logged under the name before it was taken by someone else
+		{other, "renamed", at.Add(time.Second)},
+	} {
+		err := app.domains.audit.InsertEvent(&data.AuditEvent{
+			ActorUserId:   &event.userId,
+			ActorUsername: &event.username,
+			Action:        data.AuditUserLogin,
+			CreatedAt:     event.at,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"actor=renamed", 2},
+		{"actor=other", 1},
+		{"actor=before", 0},
+		{"since=2024-01-02T15:04:05Z", 3},
+		{"since=2024-01-02T15:04:05.4Z", 2},
+		{"since=2024-01-02T16:04:05.6%2B01:00", 1},
+		{"until=2024-01-02T15:04:05.5Z", 1},
+		{"until=2024-01-02T15:04:06Z", 2},
+		{"actor=renamed&since=2024-01-02T15:04:05.4Z", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serveAs(app.getAuditEventsHandler, jsonRequest(http.MethodGet, "/api/admin/audit?"+tt.query, ""), renamed)
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", w.Code, w.Body)
+			}
+
+			var response struct {
+				AuditEvents []data.AuditEvent `json:"auditEvents"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.AuditEvents) != tt.want {
+				t.Errorf("got %d events, want %d", len(response.AuditEvents), tt.want)
+			}
+		})
+	}
+
+	t.Run("retention", func(t *testing.T) {
+		deleted, err := app.domains.audit.DeleteEventsBefore(at.Add(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 2 {
+			t.Errorf("deleted %d events, want 2", deleted)
+		}
+	})
+}
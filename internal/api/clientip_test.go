@@ -0,0 +1,58 @@
+package conduit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIp(t *testing.T) {
+	trustedProxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &Application{trustedProxies: trustedProxies}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor []string
+		want          string
+	}{
+		{"no proxy", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"untrusted proxy", "203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"trusted single address", "192.168.1.1:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"address next to a trusted one", "192.168.1.2:1234", []string{"203.0.113.7"}, "192.168.1.2"},
+		{"trusted proxy without the header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"spoofed by the client", "10.0.0.1:1234", []string{"198.51.100.1, 203.0.113.7"}, "203.0.113.7"},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"203.0.113.7, 10.0.0.2, 192.168.1.1"}, "203.0.113.7"},
+		{"only trusted proxies", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"several headers", "10.0.0.1:1234", []string{"198.51.100.1", "203.0.113.7"}, "203.0.113.7"},
+		{"blank hops", "10.0.0.1:1234", []string{"203.0.113.7, , "}, "203.0.113.7"},
+		{"ipv6 proxy", "[2001:db8::1]:1234", []string{"2001:db9::7"}, "2001:db9::7"},
+		{"ipv4 mapped proxy", "[::ffff:10.0.0.1]:1234", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"no port", "10.0.0.1", []string{"203.0.113.7"}, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/tags", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xForwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := app.clientIp(r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	for _, proxy := range []string{"10.0.0.0/33", "10.0.0", "localhost"} {
+		if _, err := parseTrustedProxies([]string{proxy}); err == nil {
+			t.Errorf("%q: expected an error", proxy)
+		}
+	}
+}
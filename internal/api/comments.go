@@ -104,6 +104,8 @@ func (app *Application) deleteArticleCommentHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	app.audit(r, data.AuditCommentDeleted, "comment", strconv.Itoa(comment.CommentId), nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -135,7 +137,7 @@ func (app *Application) getArticleCommentsHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments}, nil)
+	err = app.writeJSONList(w, http.StatusOK, "comments", comments, nil, nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
 	}
@@ -0,0 +1,172 @@
+package conduit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+)
+
+// articleETag changes whenever anything in the article's representation changes. As well as the
+// article itself that includes things that depend on who is asking, like whether they have
+// favorited the article or follow its author. The tag is weak because the same article can be
+// sent with different encodings and indentation. It is prefixed with the article's version,
+// which is what If-Match is checked against when the article is updated.
+func articleETag(article *data.Article) string {
+	tags := slices.Clone(article.TagList)
+	slices.Sort(tags)
+
+	return versionedETag(
+		article.Version,
+		strconv.Itoa(article.ArticleId),
+		article.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		strconv.Itoa(article.FavoritesCount),
+		strings.Join(tags, ","),
+		strconv.FormatBool(article.Favorited),
+		profileFingerprint(article.Author),
+	)
+}
+
+func userETag(user *data.User) string {
+	image := ""
+	if user.Image != nil {
+		image = *user.Image
+	}
+	return versionedETag(user.Version, user.Username, user.Email, user.Bio, image, user.Token)
+}
+
+func profileETag(profile *data.Profile) string {
+	return weakETag(profileFingerprint(profile))
+}
+
+func tagsETag(tags []string) string {
+	return weakETag(tags...)
+}
+
+func profileFingerprint(profile *data.Profile) string {
+	image := ""
+	if profile.Image != nil {
+		image = *profile.Image
+	}
+	return strings.Join([]string{profile.Username, profile.Bio, image, strconv.FormatBool(profile.Following)}, "\x00")
+}
+
+func weakETag(parts ...string) string {
+	return `W/"` + fingerprint(parts...) + `"`
+}
+
+func versionedETag(version int, parts ...string) string {
+	return fmt.Sprintf(`W/"v%d-%s"`, version, fingerprint(parts...))
+}
+
+func fingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:%s;", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+// ifMatchVersion checks an If-Match header against the current version of a resource. Only the
+// version prefix of each entity tag is compared, rather than the strong comparison RFC 9110 asks
+// for, so that an update isn't rejected just because someone favorited the article in the meantime.
+func ifMatchVersion(ifMatch string, version int) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		candidate = strings.Trim(strings.TrimPrefix(candidate, "W/"), `"`)
+		prefix, _, _ := strings.Cut(candidate, "-")
+		if prefix == "v"+strconv.Itoa(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serveNotModified sets the validators and caching headers for a cacheable GET response, then
+// checks them against the request's conditional headers. If the client's copy is still fresh it
+// responds with 304 Not Modified and returns true, in which case the handler has nothing left to do.
+//
+// modifiedAt is when anything in the representation last changed, including favorites and follows,
+// or zero if that isn't known. Last-Modified only has whole seconds, so it is held back until the
+// second after modifiedAt has passed. Otherwise a change later in that same second would look no
+// newer than the copy the client was sent, and If-Modified-Since would answer 304 with it.
+func (app *Application) serveNotModified(w http.ResponseWriter, r *http.Request, etag string, modifiedAt time.Time) bool {
+	h := w.Header()
+
+	h.Set("ETag", etag)
+
+	lastModified := !modifiedAt.IsZero() && time.Since(modifiedAt) >= time.Second
+	if lastModified {
+		h.Set("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
+	}
+
+	// anything personalised for an authenticated user mustn't end up in a shared cache,
+	// and has to be revalidated since favorites and follows can change at any moment
+	h.Add("Vary", "Authorization")
+	if app.getUserContext(r).isAuthenticated {
+		h.Set("Cache-Control", "private, no-cache")
+	} else {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(app.config.Cache.PublicMaxAge.Seconds())))
+	}
+
+	if !notModified(r, etag, lastModified, modifiedAt) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there's no If-None-Match as
+// RFC 9110 asks, since entity tags are the more precise of the two
+func notModified(r *http.Request, etag string, lastModified bool, modifiedAt time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagListContains(ifNoneMatch, etag, false)
+	}
+
+	if !lastModified {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modifiedAt.Truncate(time.Second).After(since)
+}
+
+// etagListContains checks whether etag appears in a comma separated list of entity tags as sent
+// in If-Match or If-None-Match. Strong comparison requires both tags to be strong.
+func etagListContains(list, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strong {
+			if candidate == etag && !strings.HasPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package conduit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeNotModified(t *testing.T) {
+	etag := `W/"v1-abc"`
+	modifiedAt := time.Date(2024, 1, 2, 15, 4, 5, 500_000_000, time.UTC)
+	lastModified := "Tue, 02 Jan 2024 15:04:05 GMT"
+
+	tests := []struct {
+		name             string
+		headers          map[string]string
+		modifiedAt       time.Time
+		want             bool
+		wantLastModified string
+	}{
+		{"no conditions", nil, modifiedAt, false, lastModified},
+		{"matching etag", map[string]string{"If-None-Match": etag}, modifiedAt, true, lastModified},
+		{"other etag", map[string]string{"If-None-Match": `W/"v1-def"`}, modifiedAt, false, lastModified},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified}, modifiedAt, true, lastModified},
+		{"not modified since later", map[string]string{"If-Modified-Since": "Wed, 03 Jan 2024 00:00:00 GMT"}, modifiedAt, true, lastModified},
+		{"modified since", map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 15:04:04 GMT"}, modifiedAt, false, lastModified},
+		{"invalid date", map[string]string{"If-Modified-Since": "yesterday"}, modifiedAt, false, lastModified},
+		{"etag takes precedence", map[string]string{"If-None-Match": `W/"v1-def"`, "If-Modified-Since": lastModified}, modifiedAt, false, lastModified},
+		{"unknown modification time", map[string]string{"If-Modified-Since": lastModified}, time.Time{}, false, ""},
+		// the second it changed in isn't over, so a later change could still land in it
+		{"modified just now", map[string]string{"If-Modified-Since": time.Now().UTC().Add(time.Minute).Format(http.TimeFormat)}, time.Now(), false, ""},
+	}
+
+	app := &Application{}
+	app.config.Cache.PublicMaxAge = time.Minute
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/tags", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey, &userContext{}))
+
+			w := httptest.NewRecorder()
+			if got := app.serveNotModified(w, r, etag, tt.modifiedAt); got != tt.want {
+				t.Errorf("got not modified %v, want %v", got, tt.want)
+			}
+			if tt.want && w.Code != http.StatusNotModified {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusNotModified)
+			}
+			if got := w.Header().Get("Last-Modified"); got != tt.wantLastModified {
+				t.Errorf("got Last-Modified %q, want %q", got, tt.wantLastModified)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("got ETag %q, want %q", got, etag)
+			}
+		})
+	}
+}
+
+func TestModifiedAt(t *testing.T) {
+	app := newTestApplication(t)
+	author := insertUser(t, app, "author")
+	reader := insertUser(t, app, "reader")
+
+	r := jsonRequest(http.MethodPost, "/api/articles", `{'article':{'title':'Dragons','description':'Ever wonder how?','body':'Carefully.','tagList':['dragons'],'status':'draft'}}`)
+	if w := serveAs(app.createArticleHandler, r, author); w.Code != http.StatusCreated {
+		t.Fatalf("got status %d creating an article: %s", w.Code, w.Body)
+	}
+
+	articleModifiedAt := func() time.Time {
+		t.Helper()
+		article, err := app.domains.articles.GetArticleBySlug("dragons", author)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return article.ModifiedAt
+	}
+	authorModifiedAt := func() time.Time {
+		t.Helper()
+		user, err := app.domains.users.GetUserByUsername("author")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ModifiedAt
+	}
+	tagsModifiedAt := func() time.Time {
+		t.Helper()
+		modifiedAt, err := app.domains.tags.GetTagsModifiedAt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return modifiedAt
+	}
+
+	// each change is checked against the time before it, which only has milliseconds
+	changes := []struct {
+		name       string
+		modifiedAt func() time.Time
+		change     func() error
+	}{
+		{"publishing changes the article", articleModifiedAt, func() error {
+			r := jsonRequest(http.MethodPut, "/api/articles/dragons", `{'article':{'status':'published'}}`)
+			r.SetPathValue("slug", "dragons")
+			if w := serveAs(app.updateArticleHandler, r, author); w.Code != http.StatusOK {
+				return fmt.Errorf("got status %d publishing: %s", w.Code, w.Body)
+			}
+			return nil
+		}},
+		{"unpublishing changes the tags", tagsModifiedAt, func() error {
+			r := jsonRequest(http.MethodPut, "/api/articles/dragons", `{'article':{'status':'draft'}}`)
+			r.SetPathValue("slug", "dragons")
+			if w := serveAs(app.updateArticleHandler, r, author); w.Code != http.StatusOK {
+				return fmt.Errorf("got status %d unpublishing: %s", w.Code, w.Body)
+			}
+			return nil
+		}},
+		{"favoriting", articleModifiedAt, func() error {
+			return app.domains.articles.FavoriteArticle(1, reader)
+		}},
+		{"unfavoriting", articleModifiedAt, func() error {
+			return app.domains.articles.UnfavoriteArticle(1, reader)
+		}},
+		{"following", authorModifiedAt, func() error {
+			return app.domains.users.Follow(reader, author)
+		}},
+		{"unfollowing", authorModifiedAt, func() error {
+			return app.domains.users.Unfollow(reader, author)
+		}},
+		{"following changes the author's articles", articleModifiedAt, func() error {
+			return app.domains.users.Follow(reader, author)
+		}},
+	}
+
+	for _, tt := range changes {
+		before := tt.modifiedAt()
+		if before.IsZero() {
+			t.Fatalf("%s: got no modification time", tt.name)
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if err := tt.change(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if after := tt.modifiedAt(); !after.After(before) {
+			t.Errorf("%s: got modification time %v, want it after %v", tt.name, after, before)
+		}
+	}
+}
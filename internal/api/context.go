@@ -5,6 +5,7 @@ import "net/http"
 type contextKey string
 
 var userContextKey = contextKey("userContext")
+var requestIdContextKey = contextKey("requestId")
 
 type userContext struct {
 	isAuthenticated bool
@@ -22,3 +23,8 @@ func (app *Application) getUserContext(r *http.Request) *userContext {
 	}
 	return userContext
 }
+
+func (app *Application) getRequestId(r *http.Request) string {
+	requestId, _ := r.Context().Value(requestIdContextKey).(string)
+	return requestId
+}
@@ -0,0 +1,106 @@
+package conduit
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// enableCORS wraps the router, so that preflight requests can be answered for any route the
+// router knows about before method matching sends them to a 405. The router is asked which
+// pattern the actual request would be routed to, which takes care of path values like {slug}.
+func (app *Application) enableCORS(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !app.corsOriginAllowed(origin) {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		isPreflight := r.Method == http.MethodOptions && requestMethod != ""
+
+		if !isPreflight {
+			app.setCORSOriginHeaders(w, origin)
+			if len(app.config.CORS.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(app.config.CORS.ExposedHeaders, ", "))
+			}
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		probe := r.Clone(r.Context())
+		probe.Method = requestMethod
+		if _, pattern := mux.Handler(probe); pattern == "" {
+			// let the router answer with a 404 or 405 as it normally would
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		if !slices.Contains(app.config.CORS.AllowedMethods, requestMethod) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			header = strings.TrimSpace(header)
+			if header != "" && !slices.ContainsFunc(app.config.CORS.AllowedHeaders, func(allowed string) bool {
+				return strings.EqualFold(allowed, header)
+			}) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+
+		app.setCORSOriginHeaders(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(app.config.CORS.AllowedMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(app.config.CORS.AllowedHeaders, ", "))
+		if app.config.CORS.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(app.config.CORS.MaxAge.Seconds())))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (app *Application) setCORSOriginHeaders(w http.ResponseWriter, origin string) {
+	// a literal "*" isn't allowed alongside credentials, so always echo the origin back
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if app.config.CORS.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// corsOriginAllowed checks the origin against the configured origins, which are either exact
+// matches such as "https://conduit.example.com", a wildcard subdomain such as
+// "https://*.example.com", or "*" to allow any origin at all
+func (app *Application) corsOriginAllowed(origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return false
+	}
+
+	for _, allowed := range app.config.CORS.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return true
+		case strings.EqualFold(allowed, origin):
+			return true
+		case strings.Contains(allowed, "://*."):
+			scheme, domain, _ := strings.Cut(allowed, "://*.")
+			if strings.EqualFold(parsed.Scheme, scheme) && strings.HasSuffix(strings.ToLower(parsed.Host), "."+strings.ToLower(domain)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
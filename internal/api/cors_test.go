@@ -0,0 +1,63 @@
+package conduit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnableCORSPreflight(t *testing.T) {
+	app := &Application{}
+	app.config.CORS.AllowedOrigins = []string{"https://conduit.example.com"}
+	app.config.CORS.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+	app.config.CORS.AllowedHeaders = []string{"Authorization", "Content-Type"}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/tags", ok)
+	mux.Handle("DELETE /api/articles/{slug}", ok)
+	mux.Handle("PATCH /api/articles/{slug}", ok)
+
+	handler := app.enableCORS(mux)
+
+	tests := []struct {
+		name        string
+		path        string
+		origin      string
+		method      string
+		headers     string
+		wantStatus  int
+		wantAllowed bool
+	}{
+		{"known route", "/api/tags", "https://conduit.example.com", http.MethodGet, "", http.StatusNoContent, true},
+		{"path values", "/api/articles/how-to-train-your-dragon", "https://conduit.example.com", http.MethodDelete, "Authorization", http.StatusNoContent, true},
+		{"unknown route", "/api/nonexistent", "https://conduit.example.com", http.MethodGet, "", http.StatusNotFound, false},
+		{"method not routed", "/api/tags", "https://conduit.example.com", http.MethodDelete, "", http.StatusMethodNotAllowed, false},
+		{"method not allowed", "/api/articles/how-to-train-your-dragon", "https://conduit.example.com", http.MethodPatch, "", http.StatusForbidden, false},
+		{"header not allowed", "/api/tags", "https://conduit.example.com", http.MethodGet, "X-Custom", http.StatusForbidden, false},
+		{"origin not allowed", "/api/tags", "https://evil.example.com", http.MethodGet, "", http.StatusMethodNotAllowed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			allowed := w.Header().Get("Access-Control-Allow-Origin") == tt.origin
+			if allowed != tt.wantAllowed {
+				t.Errorf("got Access-Control-Allow-Origin %q, want allowed %v", w.Header().Get("Access-Control-Allow-Origin"), tt.wantAllowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package conduit
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+)
+
+const (
+	exportPending = "pending"
+	exportReady   = "ready"
+	exportFailed  = "failed"
+)
+
+// exportJob tracks an export that is too large to be generated while the client waits.
+// Jobs only live in memory, so any that are in flight when the application restarts are lost
+// and the user simply has to request a new one.
+type exportJob struct {
+	Id        string     `json:"id"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	userId    int
+	path      string
+}
+
+type exports struct {
+	mu   sync.Mutex
+	jobs map[string]*exportJob
+}
+
+// pendingFor returns the user's export that is still being generated, if there is one, so that
+// asking again doesn't start another. The caller must hold mu.
+func (e *exports) pendingFor(userId int) *exportJob {
+	for _, job := range e.jobs {
+		if job.userId == userId && job.Status == exportPending {
+			return job
+		}
+	}
+	return nil
+}
+
+// GET /api/user/export
+func (app *Application) exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId := app.getUserContext(r).userId
+
+	size, err := app.domains.users.CountUserContent(userId)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	app.audit(r, data.AuditUserExported, "user", app.getUserContext(r).username, nil)
+
+	if size <= app.config.Export.AsyncThreshold {
+		// built up front so that a failure part way through can still be reported properly
+		var buf bytes.Buffer
+		err = app.writeUserExport(&buf, userId)
+		if err != nil {
+			app.serveResponseErrorInternalServerError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="conduit-export.zip"`)
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(http.StatusOK)
+
+		_, err = buf.WriteTo(w)
+		if err != nil {
+			app.logger.Error("error writing user export", slog.Int("userId", userId), slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	id, err := newExportId()
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	app.exports.mu.Lock()
+	job := app.exports.pendingFor(userId)
+	if job == nil {
+		job = &exportJob{
+			Id:        id,
+			Status:    exportPending,
+			CreatedAt: time.Now().UTC(),
+			userId:    userId,
+		}
+
+		started := app.runInBackground("user export", func() error {
+			return app.generateUserExport(job)
+		})
+		if !started {
+			app.exports.mu.Unlock()
+			app.serveResponseErrorServiceUnavailable(w, r)
+			return
+		}
+		app.exports.jobs[id] = job
+	}
+	// respond with a copy, since the job is updated by the goroutine generating it
+	snapshot := *job
+	app.exports.mu.Unlock()
+
+	headers := make(http.Header)
+	headers.Set("Location", "/api/user/export/"+snapshot.Id)
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"export": snapshot}, headers)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// GET /api/user/export/:id
+func (app *Application) getUserExportHandler(w http.ResponseWriter, r *http.Request) {
+	app.exports.mu.Lock()
+	job, ok := app.exports.jobs[r.PathValue("id")]
+	var snapshot exportJob
+	if ok {
+		snapshot = *job
+	}
+	app.exports.mu.Unlock()
+
+	if !ok || snapshot.userId != app.getUserContext(r).userId {
+		app.serveResponseErrorNotFound(w, r)
+		return
+	}
+
+	if snapshot.Status == exportReady {
+		snapshot.URL = app.signExportURL(snapshot.Id, *snapshot.ExpiresAt)
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"export": snapshot}, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// GET /api/exports/:id/download
+func (app *Application) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	expiresUnix, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || !app.validExportSignature(id, expiresUnix, r.URL.Query().Get("signature")) {
+		app.serveResponseErrorForbidden(w, r)
+		return
+	}
+
+	if time.Now().Unix() > expiresUnix {
+		app.serveResponseErrorForbidden(w, r)
+		return
+	}
+
+	app.exports.mu.Lock()
+	job, ok := app.exports.jobs[id]
+	var path string
+	if ok && job.Status == exportReady {
+		path = job.path
+	}
+	app.exports.mu.Unlock()
+
+	if path == "" {
+		app.serveResponseErrorNotFound(w, r)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="conduit-export.zip"`)
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
+
+func (app *Application) generateUserExport(job *exportJob) (retErr error) {
+	dir := app.config.Export.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, fmt.Sprintf("conduit-export-%s.zip", job.Id))
+
+	defer func() {
+		app.exports.mu.Lock()
+		defer app.exports.mu.Unlock()
+
+		if retErr != nil {
+			job.Status = exportFailed
+			os.Remove(path)
+			return
+		}
+
+		expiresAt := time.Now().UTC().Add(app.config.Export.LinkTTL)
+		job.Status = exportReady
+		job.ExpiresAt = &expiresAt
+		job.path = path
+	}()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating export file: %w", err)
+	}
+
+	err = app.writeUserExport(file, job.userId)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// expireExports removes exports whose download link has expired, along with their files
+func (app *Application) expireExports() error {
+	app.exports.mu.Lock()
+	defer app.exports.mu.Unlock()
+
+	now := time.Now()
+	for id, job := range app.exports.jobs {
+		expired := job.ExpiresAt != nil && now.After(*job.ExpiresAt)
+		abandoned := job.Status == exportFailed && now.Sub(job.CreatedAt) > app.config.Export.LinkTTL
+		if !expired && !abandoned {
+			continue
+		}
+
+		if job.path != "" {
+			if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing expired export: %w", err)
+			}
+		}
+		delete(app.exports.jobs, id)
+	}
+
+	return nil
+}
+
+// writeUserExport writes a zip archive containing everything we hold about a user
+func (app *Application) writeUserExport(w io.Writer, userId int) error {
+	user, err := app.domains.users.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	err = writeExportFile(zw, "profile.json", []any{envelope{"user": user}})
+	if err != nil {
+		return err
+	}
+
+	// every article the user has written, not just the published ones
+	authoredFilters := &data.UserArticleFilters{}
+	authored, err := app.exportArticles(&authoredFilters.PaginationFilters, func() ([]*data.BodylessArticle, *data.PageCursors, error) {
+		return app.domains.articles.GetUserArticles(authoredFilters, userId)
+	})
+	if err != nil {
+		return err
+	}
+
+	articles := make([]any, 0, len(authored))
+	for _, bodyless := range authored {
+		article, err := app.domains.articles.GetArticleBySlug(bodyless.Slug, userId)
+		if err != nil {
+			return err
+		}
+		articles = append(articles, article)
+	}
+
+	err = writeExportFile(zw, "articles.ndjson", articles)
+	if err != nil {
+		return err
+	}
+
+	favoritedFilters := &data.ArticleFilters{Favorited: &user.Username}
+	favorited, err := app.exportArticles(&favoritedFilters.PaginationFilters, func() ([]*data.BodylessArticle, *data.PageCursors, error) {
+		return app.domains.articles.GetArticles(favoritedFilters, userId)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writeExportFile(zw, "favorites.ndjson", toAnySlice(favorited))
+	if err != nil {
+		return err
+	}
+
+	comments, err := app.domains.comments.GetCommentsByUser(userId)
+	if err != nil {
+		return err
+	}
+
+	err = writeExportFile(zw, "comments.ndjson", toAnySlice(comments))
+	if err != nil {
+		return err
+	}
+
+	followers, err := app.domains.users.GetFollowers(userId)
+	if err != nil {
+		return err
+	}
+
+	err = writeExportFile(zw, "followers.ndjson", toAnySlice(followers))
+	if err != nil {
+		return err
+	}
+
+	following, err := app.domains.users.GetFollowing(userId)
+	if err != nil {
+		return err
+	}
+
+	err = writeExportFile(zw, "following.ndjson", toAnySlice(following))
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// exportArticles calls getPage until every article in a list has been read, moving the cursor in
+// pagination on to the next page each time
+func (app *Application) exportArticles(pagination *data.PaginationFilters, getPage func() ([]*data.BodylessArticle, *data.PageCursors, error)) ([]*data.BodylessArticle, error) {
+	all := make([]*data.BodylessArticle, 0)
+	pagination.Limit = 100
+
+	for {
+		page, cursors, err := getPage()
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, page...)
+
+		if cursors.Next == nil {
+			return all, nil
+		}
+		pagination.Cursor, err = data.DecodeCursor(*cursors.Next)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeExportFile adds a file to the archive with one JSON document per line
+func writeExportFile(zw *zip.Writer, name string, records []any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("error adding %s to export: %w", name, err)
+	}
+
+	enc := json.NewEncoder(f)
+	for _, record := range records {
+		if err = enc.Encode(record); err != nil {
+			return fmt.Errorf("error writing %s to export: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func toAnySlice[T any](items []T) []any {
+	result := make([]any, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	return result
+}
+
+func newExportId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// signExportURL produces a download link that can be used without a token until it expires
+func (app *Application) signExportURL(id string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	return fmt.Sprintf("/api/exports/%s/download?expires=%d&signature=%s", id, expires, app.exportSignature(id, expires))
+}
+
+func (app *Application) exportSignature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, app.config.JWT.SecretKey)
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (app *Application) validExportSignature(id string, expires int64, signature string) bool {
+	expected := app.exportSignature(id, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,92 @@
+package conduit
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExportUser(t *testing.T) {
+	app := newTestApplication(t)
+	app.exports.jobs = make(map[string]*exportJob)
+	app.config.Export.Dir = t.TempDir()
+	app.config.Export.LinkTTL = time.Hour
+	userId := insertUser(t, app, "jake")
+
+	export := func() (int, string) {
+		t.Helper()
+		w := serveAs(app.exportUserHandler, jsonRequest(http.MethodGet, "/api/user/export", ""), userId)
+		if w.Code != http.StatusAccepted {
+			return w.Code, ""
+		}
+		var response struct {
+			Export exportJob `json:"export"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if location := w.Header().Get("Location"); location != "/api/user/export/"+response.Export.Id {
+			t.Errorf("got Location %q for export %s", location, response.Export.Id)
+		}
+		return w.Code, response.Export.Id
+	}
+
+	t.Run("while the client waits", func(t *testing.T) {
+		app.config.Export.AsyncThreshold = 100
+
+		w := serveAs(app.exportUserHandler, jsonRequest(http.MethodGet, "/api/user/export", ""), userId)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+		if got := w.Header().Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+			t.Errorf("got Content-Length %s for %d bytes", got, w.Body.Len())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(zr.File) == 0 || zr.File[0].Name != "profile.json" {
+			t.Errorf("got an archive without the profile")
+		}
+	})
+
+	t.Run("in the background", func(t *testing.T) {
+		app.config.Export.AsyncThreshold = -1
+
+		status, first := export()
+		if status != http.StatusAccepted {
+			t.Fatalf("got status %d", status)
+		}
+		app.jobs.wg.Wait()
+		if _, next := export(); next == first {
+			t.Errorf("got export %s again once it was ready", first)
+		}
+		app.jobs.wg.Wait()
+
+		pending := &exportJob{Id: "pending", Status: exportPending, CreatedAt: time.Now().UTC(), userId: userId}
+		app.exports.jobs[pending.Id] = pending
+		if _, again := export(); again != pending.Id {
+			t.Errorf("got export %s while %s was pending", again, pending.Id)
+		}
+		delete(app.exports.jobs, pending.Id)
+	})
+
+	t.Run("shutting down", func(t *testing.T) {
+		app.config.Export.AsyncThreshold = -1
+		app.jobs.stopped = true
+		defer func() { app.jobs.stopped = false }()
+
+		if status, _ := export(); status != http.StatusServiceUnavailable {
+			t.Errorf("got status %d, want %d", status, http.StatusServiceUnavailable)
+		}
+		for _, job := range app.exports.jobs {
+			if job.Status == exportPending {
+				t.Errorf("export %s was left pending", job.Id)
+			}
+		}
+	})
+}
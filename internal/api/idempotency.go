@@ -0,0 +1,185 @@
+package conduit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+	"realworld.tayler.io/internal/validator"
+)
+
+// response headers worth replaying along with the body
+var idempotentHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// keyedMutex serializes work per key, so that a retry arriving while the original
+// request is still being handled waits for it rather than running a second time
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (km *keyedMutex) lock(key string) func() {
+	km.mu.Lock()
+	l, ok := km.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		km.locks[key] = l
+	}
+	l.refs++
+	km.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		km.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(km.locks, key)
+		}
+		km.mu.Unlock()
+	}
+}
+
+// idempotent lets clients safely retry a POST by sending the same Idempotency-Key header. The
+// first response for each user and key is stored and replayed for any retry with the same body.
+// It must come after requireAuthentication since keys are scoped to the user.
+func (app *Application) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator.New()
+		if v.Check(len(key) <= 255, "Idempotency-Key", "must not be longer than 255 characters"); !v.Valid() {
+			app.serveResponseErrorUnprocessableEntity(w, v)
+			return
+		}
+
+		// same limit as readJSON, which will enforce it properly when the handler reads the body
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1_048_576+1))
+		if err != nil {
+			app.serveResponseErrorInternalServerError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		userId := app.getUserContext(r).userId
+
+		unlock := app.idempotencyLocks.lock(strconv.Itoa(userId) + ":" + key)
+		defer unlock()
+
+		notBefore := time.Now().Add(-app.config.Idempotency.TTL)
+		record, err := app.domains.idempotency.GetRecord(userId, key, notBefore)
+		switch {
+		case err == nil:
+			if record.RequestHash != requestHash {
+				v.AddError("Idempotency-Key", "has already been used for a different request")
+				app.serveResponseErrorUnprocessableEntity(w, v)
+				return
+			}
+
+			for _, header := range idempotentHeaders {
+				if value := record.ResponseHeaders.Get(header); value != "" {
+					w.Header().Set(header, value)
+				}
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.ResponseStatus)
+			w.Write(record.ResponseBody)
+			return
+
+		case !errors.Is(err, data.ErrIdempotencyKeyNotFound):
+			app.serveResponseErrorInternalServerError(w, err)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// server errors aren't stored so that the client can retry and hopefully succeed
+		if rec.status >= 500 {
+			return
+		}
+
+		headers := make(http.Header)
+		for _, header := range idempotentHeaders {
+			if value := w.Header().Get(header); value != "" {
+				headers.Set(header, value)
+			}
+		}
+
+		err = app.domains.idempotency.SaveRecord(&data.IdempotencyRecord{
+			UserId:          userId,
+			Key:             key,
+			RequestHash:     requestHash,
+			ResponseStatus:  rec.status,
+			ResponseHeaders: headers,
+			ResponseBody:    rec.body.Bytes(),
+			CreatedAt:       time.Now().UTC(),
+		})
+		if err != nil {
+			// the response has already been sent, so the worst case is a retry isn't deduplicated
+			app.logger.Error(err.Error(), slog.String("requestId", app.getRequestId(r)))
+		}
+	})
+}
+
+// recordingWriter keeps a copy of everything written to the response
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (app *Application) expireIdempotencyKeys() error {
+	cutoff := time.Now().Add(-app.config.Idempotency.TTL)
+
+	expired, err := app.domains.idempotency.DeleteRecordsBefore(cutoff)
+	if expired > 0 {
+		app.logger.Info("expired idempotency keys", slog.Int64("count", expired))
+	}
+
+	return err
+}
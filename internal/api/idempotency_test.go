@@ -0,0 +1,113 @@
+package conduit
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+)
+
+func TestIdempotentReplay(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// every connection to :memory: has a database of its own
+	db.SetMaxOpenConns(1)
+
+	// the keys belong to users, so their table is needed too
+	for _, name := range []string{"000001_create_users_table", "000012_create_idempotency_keys_table"} {
+		migration, err := os.ReadFile("../../migrations/" + name + ".up.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.Exec(string(migration)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.Exec(`INSERT INTO User (UserId, Email, PasswordHash, Username) VALUES (1, 'a@example.com', '', 'a'), (2, 'b@example.com', '', 'b')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &Application{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		idempotencyLocks: keyedMutex{
+			locks: make(map[string]*keyedLock),
+		},
+	}
+	app.config.Idempotency.TTL = time.Hour
+	app.domains.idempotency = data.IdempotencyRepository{DB: db, TimeoutSeconds: 5}
+
+	calls := 0
+	handler := app.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		status := http.StatusCreated
+		if string(body) == "fail" {
+			status = http.StatusInternalServerError
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/articles/"+string(body))
+		w.WriteHeader(status)
+		io.WriteString(w, `{"call":`+strings.Repeat("1", calls)+`}`)
+	}))
+
+	tests := []struct {
+		name         string
+		userId       int
+		key          string
+		body         string
+		wantStatus   int
+		wantBody     string
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{"no key", 1, "", "a", http.StatusCreated, `{"call":1}`, false, 1},
+		{"first request", 1, "key-1", "a", http.StatusCreated, `{"call":11}`, false, 2},
+		{"retry", 1, "key-1", "a", http.StatusCreated, `{"call":11}`, true, 2},
+		{"different body", 1, "key-1", "b", http.StatusUnprocessableEntity, "", false, 2},
+		{"other user", 2, "key-1", "b", http.StatusCreated, `{"call":111}`, false, 3},
+		{"server error", 1, "key-2", "fail", http.StatusInternalServerError, `{"call":1111}`, false, 4},
+		{"retry after a server error", 1, "key-2", "fail", http.StatusInternalServerError, `{"call":11111}`, false, 5},
+		{"key too long", 1, strings.Repeat("k", 256), "a", http.StatusUnprocessableEntity, "", false, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/articles", strings.NewReader(tt.body))
+			if tt.key != "" {
+				r.Header.Set("Idempotency-Key", tt.key)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey, &userContext{isAuthenticated: true, userId: tt.userId}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("got replayed %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && w.Header().Get("Location") != "/api/articles/"+tt.body {
+				t.Errorf("got Location %q, want it replayed", w.Header().Get("Location"))
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("got %d calls to the handler, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
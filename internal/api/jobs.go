@@ -0,0 +1,150 @@
+package conduit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// guards wg.Add against stopJobs waiting on wg, since Add mustn't race with Wait
+	mu      sync.Mutex
+	stopped bool
+}
+
+func (app *Application) startJobs() {
+	app.jobs.ctx, app.jobs.cancel = context.WithCancel(context.Background())
+
+	app.runPeriodically("purge deleted users", app.config.Accounts.PurgeInterval, app.purgeDeletedUsers)
+	app.runPeriodically("expire exports", app.config.Export.LinkTTL, app.expireExports)
+	if app.config.RateLimit.Enabled {
+		app.runPeriodically("sweep rate limiter", app.config.RateLimit.IdleTimeout, app.sweepRateLimiter)
+	}
+	if app.config.Audit.RetentionPeriod > 0 {
+		app.runPeriodically("prune audit log", app.config.Audit.PruneInterval, app.pruneAuditLog)
+	}
+	app.runPeriodically("expire idempotency keys", app.config.Idempotency.CleanupInterval, app.expireIdempotencyKeys)
+	app.runPeriodically("publish scheduled articles", app.config.Publishing.Interval, app.publishScheduledArticles)
+	// scores are stale after a restart, and don't exist at all on a fresh database
+	app.runImmediatelyAndPeriodically("compute trending scores", app.config.Trending.Interval, app.computeTrendingScores)
+}
+
+func (app *Application) stopJobs() {
+	app.jobs.mu.Lock()
+	app.jobs.stopped = true
+	app.jobs.mu.Unlock()
+
+	app.jobs.cancel()
+	app.jobs.wg.Wait()
+}
+
+// runInBackground runs fn once without waiting for it, reporting false if the application is
+// shutting down and it wasn't started
+func (app *Application) runInBackground(name string, fn func() error) bool {
+	app.jobs.mu.Lock()
+	defer app.jobs.mu.Unlock()
+
+	if app.jobs.stopped {
+		return false
+	}
+
+	app.jobs.wg.Add(1)
+	go func() {
+		defer app.jobs.wg.Done()
+		app.runJob(name, fn)
+	}()
+
+	return true
+}
+
+// runPeriodically calls fn every interval until the application shuts down.
+// A job with a non-positive interval is disabled.
+func (app *Application) runPeriodically(name string, interval time.Duration, fn func() error) {
+	app.schedule(name, interval, false, fn)
+}
+
+// runImmediatelyAndPeriodically is runPeriodically for jobs that can't wait a whole interval
+// before first running, it calls fn straight away in the background as well
+func (app *Application) runImmediatelyAndPeriodically(name string, interval time.Duration, fn func() error) {
+	app.schedule(name, interval, true, fn)
+}
+
+func (app *Application) schedule(name string, interval time.Duration, immediately bool, fn func() error) {
+	if interval <= 0 {
+		app.logger.Info("background job disabled", slog.String("job", name))
+		return
+	}
+
+	app.jobs.wg.Add(1)
+	go func() {
+		defer app.jobs.wg.Done()
+
+		if immediately {
+			app.runJob(name, fn)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-app.jobs.ctx.Done():
+				return
+			case <-ticker.C:
+				app.runJob(name, fn)
+			}
+		}
+	}()
+}
+
+// runJob runs a single iteration of a background job, making sure a panic or
+// error is logged rather than taking the whole application down with it
+func (app *Application) runJob(name string, fn func() error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error("background job panicked", slog.String("job", name), slog.Any("error", err))
+		}
+	}()
+
+	if err := fn(); err != nil {
+		app.logger.Error("background job failed", slog.String("job", name), slog.String("error", err.Error()))
+	}
+}
+
+func (app *Application) purgeDeletedUsers() error {
+	cutoff := time.Now().Add(-app.config.Accounts.DeletionGracePeriod)
+
+	purged, err := app.domains.users.PurgeDeletedUsers(cutoff)
+	if purged > 0 {
+		app.related.invalidate()
+		app.renders.invalidate()
+		app.logger.Info("purged deleted users", slog.Int("count", purged))
+	}
+
+	return err
+}
+
+func (app *Application) pruneAuditLog() error {
+	cutoff := time.Now().Add(-app.config.Audit.RetentionPeriod)
+
+	pruned, err := app.domains.audit.DeleteEventsBefore(cutoff)
+	if pruned > 0 {
+		app.logger.Info("pruned audit log", slog.Int64("count", pruned))
+	}
+
+	return err
+}
+
+func (app *Application) publishScheduledArticles() error {
+	published, err := app.domains.articles.PublishScheduledArticles(time.Now())
+	if published > 0 {
+		app.related.invalidate()
+		app.logger.Info("published scheduled articles", slog.Int64("count", published))
+	}
+
+	return err
+}
@@ -0,0 +1,41 @@
+package conduit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRunImmediatelyAndPeriodically(t *testing.T) {
+	app := &Application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	app.jobs.ctx, app.jobs.cancel = context.WithCancel(context.Background())
+
+	ran := make(chan string, 3)
+	record := func(name string) func() error {
+		return func() error {
+			ran <- name
+			return nil
+		}
+	}
+
+	app.runImmediatelyAndPeriodically("immediately", time.Hour, record("immediately"))
+	app.runPeriodically("periodically", time.Hour, record("periodically"))
+	app.runImmediatelyAndPeriodically("disabled", 0, record("disabled"))
+
+	select {
+	case name := <-ran:
+		if name != "immediately" {
+			t.Errorf("%s ran before its interval was up", name)
+		}
+	case <-time.After(time.Second):
+		t.Error("job didn't run until its interval was up")
+	}
+
+	app.stopJobs()
+	close(ran)
+	for name := range ran {
+		t.Errorf("%s ran before its interval was up", name)
+	}
+}
@@ -0,0 +1,80 @@
+package conduit
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"realworld.tayler.io/internal/data"
+)
+
+// renderCache keeps the most recently rendered body of each article. Entries are checked against
+// a hash of the body they were rendered from rather than the article's version, since SQLite
+// hands the id of the newest article out again once it is deleted and the next article to get
+// it starts over at version 1.
+type renderCache struct {
+	mu      sync.Mutex
+	entries map[int]renderedArticle
+}
+
+type renderedArticle struct {
+	digest [sha256.Size]byte
+	body   *data.RenderedBody
+}
+
+// evict drops the article's rendered body, so that it can't be served for another article
+// that is given the same id later
+func (c *renderCache) evict(articleId int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, articleId)
+}
+
+// invalidate drops every rendered body, for when articles are removed without knowing which
+func (c *renderCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
+
+// renderArticleBody renders the article's Markdown body, or returns it from the cache if this
+// body has been rendered already
+func (app *Application) renderArticleBody(article *data.Article) (*data.RenderedBody, error) {
+	digest := sha256.Sum256([]byte(article.Body))
+
+	app.renders.mu.Lock()
+	cached, ok := app.renders.entries[article.ArticleId]
+	app.renders.mu.Unlock()
+
+	if ok && cached.digest == digest {
+		return cached.body, nil
+	}
+
+	// rendered without holding the lock, two requests for the same article may both render it
+	// but will come up with the same thing
+	body, err := data.RenderMarkdown(article.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.config.Markdown.CacheSize <= 0 {
+		return body, nil
+	}
+
+	app.renders.mu.Lock()
+	defer app.renders.mu.Unlock()
+
+	// make room by dropping whichever article map iteration turns up first, which is as good
+	// as any other since there's nothing to say which articles will be read again
+	if _, ok := app.renders.entries[article.ArticleId]; !ok && len(app.renders.entries) >= app.config.Markdown.CacheSize {
+		for articleId := range app.renders.entries {
+			delete(app.renders.entries, articleId)
+			break
+		}
+	}
+
+	app.renders.entries[article.ArticleId] = renderedArticle{digest: digest, body: body}
+
+	return body, nil
+}
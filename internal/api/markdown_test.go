@@ -0,0 +1,88 @@
+package conduit
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"realworld.tayler.io/internal/data"
+)
+
+func TestRenderCacheAfterDelete(t *testing.T) {
+	app := newTestApplication(t)
+	author := insertUser(t, app, "author")
+	other := insertUser(t, app, "other")
+
+	type articleResponse struct {
+		Article struct {
+			Slug     string `json:"slug"`
+			BodyHTML string `json:"bodyHtml"`
+		} `json:"article"`
+	}
+
+	create := func(userId int, title, body string) string {
+		t.Helper()
+		w := serveAs(app.createArticleHandler, jsonRequest(http.MethodPost, "/api/articles",
+			`{'article':{'title':'`+title+`','description':'Ever wonder how?','body':'`+body+`'}}`), userId)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("got status %d creating an article: %s", w.Code, w.Body)
+		}
+		var response articleResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Article.Slug
+	}
+
+	get := func(slug string) string {
+		t.Helper()
+		r := jsonRequest(http.MethodGet, "/api/articles/"+slug+"?html=true", "")
+		r.SetPathValue("slug", slug)
+		w := serveAs(app.getArticleHandler, r, 0)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d getting an article: %s", w.Code, w.Body)
+		}
+		var response articleResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Article.BodyHTML
+	}
+
+	secret := create(author, "Secret", "Secret plans.")
+	if got := get(secret); got != "<p>Secret plans.</p>\n" {
+		t.Fatalf("got body %q for the first article", got)
+	}
+
+	r := jsonRequest(http.MethodDelete, "/api/articles/"+secret, "")
+	r.SetPathValue("slug", secret)
+	if w := serveAs(app.deleteArticleHandler, r, author); w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d deleting the article: %s", w.Code, w.Body)
+	}
+
+	// the newest article's id is handed out again once it is deleted
+	public := create(other, "Public", "Nothing to hide.")
+	if got := get(public); got != "<p>Nothing to hide.</p>\n" {
+		t.Errorf("got body %q for the article given the deleted article's id, want its own", got)
+	}
+}
+
+func TestRenderCacheSameIdAndVersion(t *testing.T) {
+	app := &Application{renders: renderCache{entries: make(map[int]renderedArticle)}}
+	app.config.Markdown.CacheSize = 10
+
+	// an entry that outlived its article isn't served for another article with the same id and version
+	first := data.Article{BodylessArticle: data.BodylessArticle{ArticleId: 1}, Version: 1, Body: "First."}
+	if _, err := app.renderArticleBody(&first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := data.Article{BodylessArticle: data.BodylessArticle{ArticleId: 1}, Version: 1, Body: "Second."}
+	body, err := app.renderArticleBody(&second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.HTML != "<p>Second.</p>\n" {
+		t.Errorf("got body %q, want the second article's", body.HTML)
+	}
+}
@@ -2,17 +2,25 @@ package conduit
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"realworld.tayler.io/internal/data"
 )
 
 // panic recovery middleware
+// request id middleware
 // context middleware
 // require auth middleware
+// require admin middleware
+
+// request ids supplied by a client or proxy are only trusted if they look sane
+var requestIdRX = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
 
 func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -31,6 +39,28 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+func (app *Application) assignRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		requestId := r.Header.Get("X-Request-Id")
+		if !requestIdRX.MatchString(requestId) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				app.serveResponseErrorInternalServerError(w, err)
+				return
+			}
+			requestId = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-Id", requestId)
+
+		ctx := context.WithValue(r.Context(), requestIdContextKey, requestId)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *Application) authenticateUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -46,13 +76,24 @@ func (app *Application) authenticateUser(next http.Handler) http.Handler {
 			} else {
 				claims, ok := token.Claims.(*data.CustomClaims)
 				if ok {
-					usercontext = &userContext{
-						isAuthenticated: true,
-						userId:          claims.UserId,
-						username:        claims.Username,
-						token:           rawToken,
+					// tokens are revoked by deleting the account they were issued to, so
+					// make sure the user still exists, hasn't requested deletion and hasn't
+					// restored their account since the token was issued
+					isActive, err := app.domains.users.IsActive(claims.UserId, claims.TokenVersion)
+					switch {
+					case err != nil:
+						app.serveResponseErrorInternalServerError(w, err)
+						return
+					case !isActive:
+						app.logger.Warn("token presented for inactive user", "userId", claims.UserId)
+					default:
+						usercontext = &userContext{
+							isAuthenticated: true,
+							userId:          claims.UserId,
+							username:        claims.Username,
+							token:           rawToken,
+						}
 					}
-					// we could validate such a user exists here too etc, but skipping for now
 				} else {
 					app.logger.Error("there was a problem accessing user claims")
 				}
@@ -79,3 +120,21 @@ func (app *Application) requireAuthentication(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (app *Application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		isAdmin, err := app.domains.users.IsAdmin(app.getUserContext(r).userId)
+		if err != nil {
+			app.serveResponseErrorInternalServerError(w, err)
+			return
+		}
+
+		if !isAdmin {
+			app.serveResponseErrorForbidden(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,285 @@
+package conduit
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// content codings we can produce, in the order we prefer them when a client rates them equally
+var supportedEncodings = []string{"zstd", "br", "gzip"}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any {
+		gw, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return &gzipEncoder{gw}
+	}},
+	"zstd": {New: func() any {
+		zw, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return zw
+	}},
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+}
+
+// gzip.Writer.Reset doesn't match the signature of the other encoders
+type gzipEncoder struct {
+	*gzip.Writer
+}
+
+func (g *gzipEncoder) Reset(w io.Writer) {
+	g.Writer.Reset(w)
+}
+
+// negotiateResponse works out how the client would like the response body to be written:
+// whether JSON should be indented, and which content coding, if any, it should be compressed with.
+func (app *Application) negotiateResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		nw := &negotiatedWriter{
+			ResponseWriter: w,
+			indentJSON:     wantsIndentedJSON(r),
+			minSize:        app.config.Compression.MinSize,
+		}
+
+		if app.config.Compression.Enabled {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method != http.MethodHead {
+				nw.encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			}
+		}
+
+		defer nw.close()
+
+		next.ServeHTTP(nw, r)
+	})
+}
+
+// clients can ask for indented JSON with ?pretty=1 or with a parameter on the media type
+// they accept, as in "Accept: application/json; pretty=true"
+func wantsIndentedJSON(r *http.Request) bool {
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		return pretty
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err != nil || (mediaType != "application/json" && mediaType != "*/*") {
+			continue
+		}
+		if pretty, err := strconv.ParseBool(params["pretty"]); err == nil {
+			return pretty
+		}
+	}
+
+	return false
+}
+
+// negotiateEncoding picks the supported content coding with the highest quality value in the
+// Accept-Encoding header, or an empty string if the response should not be compressed
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if coding == "*" {
+			wildcard = q
+		} else {
+			qualities[coding] = q
+		}
+	}
+
+	candidates := make([]string, 0, len(supportedEncodings))
+	for _, encoding := range supportedEncodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > 0 {
+			qualities[encoding] = q
+			candidates = append(candidates, encoding)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return qualities[candidates[i]] > qualities[candidates[j]]
+	})
+
+	return candidates[0]
+}
+
+// negotiatedWriter holds the body back until it has seen enough of it to decide whether it is
+// worth compressing, then either compresses it or passes it through untouched from there on.
+type negotiatedWriter struct {
+	http.ResponseWriter
+	indentJSON bool
+	encoding   string
+	minSize    int
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder encoder
+}
+
+func (nw *negotiatedWriter) WriteHeader(status int) {
+	if nw.status != 0 {
+		return
+	}
+	nw.status = status
+
+	// responses like 204 and 304 have no body to wait for
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		nw.decide(false)
+	}
+}
+
+func (nw *negotiatedWriter) Write(p []byte) (int, error) {
+	if nw.status == 0 {
+		nw.WriteHeader(http.StatusOK)
+	}
+
+	if nw.decided {
+		if nw.encoder != nil {
+			return nw.encoder.Write(p)
+		}
+		return nw.ResponseWriter.Write(p)
+	}
+
+	nw.buf = append(nw.buf, p...)
+	if len(nw.buf) >= nw.minSize {
+		if err := nw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush sends whatever has been written so far, which for a streamed response
+// means deciding whether to compress before the whole body has been seen
+func (nw *negotiatedWriter) Flush() {
+	if !nw.decided {
+		nw.decide(len(nw.buf) >= nw.minSize)
+	}
+	if nw.encoder != nil {
+		nw.encoder.Flush()
+	}
+	http.NewResponseController(nw.ResponseWriter).Flush()
+}
+
+func (nw *negotiatedWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
+
+func (nw *negotiatedWriter) decide(bigEnough bool) error {
+	nw.decided = true
+
+	if bigEnough && nw.compressible() {
+		h := nw.Header()
+		h.Set("Content-Encoding", nw.encoding)
+		h.Del("Content-Length")
+
+		nw.encoder = encoderPools[nw.encoding].Get().(encoder)
+		nw.encoder.Reset(nw.ResponseWriter)
+	}
+
+	if nw.status == 0 {
+		nw.status = http.StatusOK
+	}
+	nw.ResponseWriter.WriteHeader(nw.status)
+
+	if len(nw.buf) == 0 {
+		return nil
+	}
+
+	buf := nw.buf
+	nw.buf = nil
+	if nw.encoder != nil {
+		_, err := nw.encoder.Write(buf)
+		return err
+	}
+	_, err := nw.ResponseWriter.Write(buf)
+	return err
+}
+
+func (nw *negotiatedWriter) compressible() bool {
+	h := nw.Header()
+
+	// responses with a length or range set up front (e.g. from http.ServeContent) are left alone,
+	// as are responses a handler has already encoded itself
+	if nw.encoding == "" || h.Get("Content-Encoding") != "" || h.Get("Content-Length") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/javascript" ||
+		mediaType == "image/svg+xml"
+}
+
+func (nw *negotiatedWriter) close() {
+	if !nw.decided {
+		if nw.status == 0 && len(nw.buf) == 0 {
+			// the handler didn't write anything, which net/http treats as an empty 200
+			return
+		}
+		nw.decide(false)
+	}
+
+	if nw.encoder != nil {
+		nw.encoder.Close()
+		encoderPools[nw.encoding].Put(nw.encoder)
+		nw.encoder = nil
+	}
+}
+
+// jsonIndented reports whether the client asked for indented JSON, looking through
+// any other writers that middleware may have wrapped around the negotiated one
+func jsonIndented(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *negotiatedWriter:
+			return rw.indentJSON
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
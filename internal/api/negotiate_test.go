@@ -0,0 +1,96 @@
+package conduit
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+)
+
+// benchmarkArticles is a page of articles about the size of the largest list the API serves
+func benchmarkArticles() []*data.BodylessArticle {
+	articles := make([]*data.BodylessArticle, 100)
+	for i := range articles {
+		articles[i] = &data.BodylessArticle{
+			ArticleId:   i + 1,
+			Title:       "How to train your dragon",
+			Slug:        "how-to-train-your-dragon",
+			Description: "Ever wonder how? " + strings.Repeat("Dragons are hard to train. ", 4),
+			TagList:     []string{"dragons", "training", "howto"},
+			CreatedAt:   time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+			UpdatedAt:   time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+			Status:      data.ArticleStatusPublished,
+			Author: &data.Profile{
+				Username: "jake",
+				Bio:      "I work at statefarm",
+			},
+		}
+	}
+	return articles
+}
+
+func benchmarkApp() *Application {
+	app := &Application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	app.config.Compression.Enabled = true
+	app.config.Compression.MinSize = 1024
+	return app
+}
+
+func BenchmarkWriteJSON(b *testing.B) {
+	app := benchmarkApp()
+	articles := benchmarkArticles()
+
+	tests := []struct {
+		name   string
+		accept string
+	}{
+		{"compact", "application/json"},
+		{"indented", "application/json; pretty=true"},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			handler := app.negotiateResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				app.writeJSONList(w, http.StatusOK, "articles", articles, envelope{"articlesCount": len(articles)}, nil)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/api/articles", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				b.SetBytes(int64(w.Body.Len()))
+			}
+		})
+	}
+}
+
+func BenchmarkNegotiate(b *testing.B) {
+	app := benchmarkApp()
+	articles := benchmarkArticles()
+
+	for _, encoding := range []string{"identity", "gzip", "zstd", "br"} {
+		b.Run(encoding, func(b *testing.B) {
+			handler := app.negotiateResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				app.writeJSONList(w, http.StatusOK, "articles", articles, envelope{"articlesCount": len(articles)}, nil)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/api/articles", nil)
+			r.Header.Set("Accept-Encoding", encoding)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				b.SetBytes(int64(w.Body.Len()))
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package conduit
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"realworld.tayler.io/internal/validator"
+)
+
+// openapi.json is the API contract, it has to be kept up to date by hand whenever a route or DTO changes
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIDocument is the small part of the OpenAPI document needed to
+// check route coverage and to validate request bodies
+type openAPIDocument struct {
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*jsonSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *jsonSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// jsonSchema supports just enough of JSON Schema for the request bodies in the spec
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []string               `json:"enum"`
+	Format               string                 `json:"format"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+}
+
+// type can be a single type or a list of them, as in ["string", "null"]
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+func parseOpenAPISpec() (*openAPIDocument, error) {
+	var doc openAPIDocument
+	err := json.Unmarshal(openAPISpec, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing openapi.json: %w", err)
+	}
+	return &doc, nil
+}
+
+// operation finds the operation documented for a route pattern such as "POST /api/articles/{slug}/comments"
+func (doc *openAPIDocument) operation(pattern string) *openAPIOperation {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return nil
+	}
+	return doc.Paths[path][strings.ToLower(method)]
+}
+
+// router records the patterns registered on it so they can be checked against the spec, see
+// TestOpenAPICoversAllRoutes
+type router struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.ServeMux.Handle(pattern, handler)
+}
+
+// GET /api/openapi.json
+func (app *Application) getOpenAPISpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>Conduit API</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js"></script>
+	<script>
+		window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+	</script>
+</body>
+</html>
+`
+
+// GET /api/docs
+func (app *Application) getAPIDocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, swaggerUIPage)
+}
+
+// validateRequest checks the request body against the schema documented for the matched route.
+// Bodies that aren't JSON at all are left for the handler's own readJSON to report, as are
+// routes without a documented body. It has to run after routing so that r.Pattern is set.
+func (app *Application) validateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if !app.config.OpenAPI.ValidateRequests {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		operation := app.openAPI.operation(r.Pattern)
+		if operation == nil || operation.RequestBody == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		schema := operation.RequestBody.Content["application/json"].Schema
+		if schema == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1_048_576+1))
+		if err != nil {
+			app.serveResponseErrorInternalServerError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator.New()
+		if app.openAPI.validate(v, schema, value, ""); !v.Valid() {
+			app.serveResponseErrorUnprocessableEntity(w, v)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validate adds an error to v for each way value doesn't match the schema. Errors are keyed on
+// the name of the property they are about, the same as the handlers' own validation, so clients
+// see the same errors whether or not the request was validated against the spec first.
+func (doc *openAPIDocument) validate(v *validator.Validator, schema *jsonSchema, value any, key string) {
+	if schema.Ref != "" {
+		resolved, ok := doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return
+		}
+		schema = resolved
+	}
+
+	if key == "" {
+		key = "body"
+	}
+
+	if len(schema.Type) > 0 && !slices.ContainsFunc(schema.Type, func(t string) bool { return jsonTypeMatches(t, value) }) {
+		v.AddError(key, "must be of type "+strings.Join(schema.Type, " or "))
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				v.AddError(name, "is required")
+			}
+		}
+		for name, property := range value {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				if string(schema.AdditionalProperties) == "false" {
+					v.AddError(name, "is not allowed")
+				}
+				continue
+			}
+			doc.validate(v, propertySchema, property, name)
+		}
+
+	case []any:
+		if schema.Items != nil {
+			for _, item := range value {
+				doc.validate(v, schema.Items, item, key)
+			}
+		}
+
+	case string:
+		length := utf8.RuneCountInString(value)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			if *schema.MinLength == 1 {
+				v.AddError(key, "must not be empty")
+			} else {
+				v.AddError(key, fmt.Sprintf("must be at least %d characters long", *schema.MinLength))
+			}
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			v.AddError(key, fmt.Sprintf("must not be more than %d characters long", *schema.MaxLength))
+		}
+		if schema.Format == "email" && !v.Matches(value, validator.EmailRX) {
+			v.AddError(key, "must be a valid email address")
+		}
+		if schema.Format == "date-time" {
+			_, err := time.Parse(time.RFC3339, value)
+			v.Check(err == nil, key, "must be an RFC 3339 date and time, e.g. 2024-01-02T15:04:05Z")
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+			v.AddError(key, "must be one of "+strings.Join(schema.Enum, ", "))
+		}
+	}
+}
+
+func jsonTypeMatches(t string, value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case json.Number:
+		if t == "integer" {
+			_, err := value.Int64()
+			return err == nil
+		}
+		return t == "number"
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package conduit
+
+import "testing"
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	doc, err := parseOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &Application{openAPI: doc}
+	app.config.OpenAPI.SwaggerUI = true
+
+	for _, pattern := range app.routes().patterns {
+		if doc.operation(pattern) == nil {
+			t.Errorf("route %q is missing from openapi.json", pattern)
+		}
+	}
+}
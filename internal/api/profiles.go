@@ -122,6 +122,10 @@ func (app *Application) getProfileHandler(w http.ResponseWriter, r *http.Request
 		profile.Following = isFollowing
 	}
 
+	if app.serveNotModified(w, r, profileETag(profile), lookupUser.ModifiedAt) {
+		return
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"profile": profile}, nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
@@ -0,0 +1,108 @@
+package conduit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a set of token buckets, one per route pattern and client
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// take removes a token from the bucket for key if there is one. It returns whether the request
+// is allowed, how many requests remain, how long until the bucket is full again, and how long
+// until the next token is available.
+func (rl *rateLimiter) take(key string, limit RateLimit, now time.Time) (bool, int, time.Duration, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), lastSeen: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.lastSeen).Seconds()*limit.Rate)
+	b.lastSeen = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	untilFull := time.Duration((float64(limit.Burst) - b.tokens) / limit.Rate * float64(time.Second))
+	var retryAfter time.Duration
+	if !allowed {
+		retryAfter = time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+
+	return allowed, int(b.tokens), untilFull, retryAfter
+}
+
+// sweep forgets about clients that haven't been seen for a while, a fresh
+// bucket is full anyway so this doesn't change anyone's limits
+func (rl *rateLimiter) sweep(idleTimeout time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for key, b := range rl.buckets {
+		if time.Since(b.lastSeen) > idleTimeout {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// rateLimit needs to run after authenticateUser so that authenticated users are limited
+// individually rather than sharing a bucket with everyone else behind the same address,
+// and after routing so that the limit for the matched route pattern can be applied
+func (app *Application) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if !app.config.RateLimit.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit, ok := app.config.RateLimit.Routes[r.Pattern]
+		if !ok {
+			limit = app.config.RateLimit.Default
+		}
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Pattern + "|ip:" + app.clientIp(r)
+		if userContext := app.getUserContext(r); userContext.isAuthenticated {
+			key = r.Pattern + "|user:" + strconv.Itoa(userContext.userId)
+		}
+
+		allowed, remaining, untilFull, retryAfter := app.rateLimiter.take(key, limit, time.Now())
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(untilFull.Seconds()))))
+
+		if !allowed {
+			app.serveResponseErrorTooManyRequests(w, r, retryAfter)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *Application) sweepRateLimiter() error {
+	app.rateLimiter.sweep(app.config.RateLimit.IdleTimeout)
+	return nil
+}
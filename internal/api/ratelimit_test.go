@@ -0,0 +1,104 @@
+package conduit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	rl := &rateLimiter{buckets: make(map[string]*bucket)}
+	limit := RateLimit{Rate: 2, Burst: 3}
+	start := time.Now()
+
+	// run in order against the same bucket
+	tests := []struct {
+		name           string
+		after          time.Duration
+		wantAllowed    bool
+		wantRemaining  int
+		wantUntilFull  time.Duration
+		wantRetryAfter time.Duration
+	}{
+		{"starts full", 0, true, 2, 500 * time.Millisecond, 0},
+		{"burst", 0, true, 1, time.Second, 0},
+		{"last of the burst", 0, true, 0, 1500 * time.Millisecond, 0},
+		{"burst used up", 0, false, 0, 1500 * time.Millisecond, 500 * time.Millisecond},
+		{"half a token refilled", 250 * time.Millisecond, false, 0, 1250 * time.Millisecond, 250 * time.Millisecond},
+		{"a token refilled", 500 * time.Millisecond, true, 0, 1500 * time.Millisecond, 0},
+		{"refill stops at the burst", time.Minute, true, 2, 500 * time.Millisecond, 0},
+	}
+
+	for _, tt := range tests {
+		allowed, remaining, untilFull, retryAfter := rl.take("GET /api/tags|ip:203.0.113.7", limit, start.Add(tt.after))
+		if allowed != tt.wantAllowed || remaining != tt.wantRemaining || untilFull != tt.wantUntilFull || retryAfter != tt.wantRetryAfter {
+			t.Errorf("%s: got (%v, %d, %v, %v), want (%v, %d, %v, %v)", tt.name,
+				allowed, remaining, untilFull, retryAfter,
+				tt.wantAllowed, tt.wantRemaining, tt.wantUntilFull, tt.wantRetryAfter)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	app := &Application{
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		rateLimiter: rateLimiter{buckets: make(map[string]*bucket)},
+	}
+	app.config.RateLimit.Enabled = true
+	app.config.RateLimit.Default = RateLimit{Rate: 0.1, Burst: 1}
+	app.config.RateLimit.Routes = map[string]RateLimit{
+		"POST /api/users/login": {Rate: 0.5, Burst: 2},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/tags", app.rateLimit(ok))
+	mux.Handle("GET /api/articles", app.rateLimit(ok))
+	mux.Handle("POST /api/users/login", app.rateLimit(ok))
+
+	// run in order, sharing the buckets
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		remoteAddr     string
+		userId         int
+		wantStatus     int
+		wantRemaining  string
+		wantRetryAfter string
+	}{
+		{"first request", http.MethodGet, "/api/tags", "203.0.113.7:1234", 0, http.StatusOK, "0", ""},
+		{"same client", http.MethodGet, "/api/tags", "203.0.113.7:5678", 0, http.StatusTooManyRequests, "0", "10"},
+		{"another address", http.MethodGet, "/api/tags", "198.51.100.1:1234", 0, http.StatusOK, "0", ""},
+		{"another route", http.MethodGet, "/api/articles", "203.0.113.7:1234", 0, http.StatusOK, "0", ""},
+		{"authenticated from the same address", http.MethodGet, "/api/tags", "203.0.113.7:1234", 1, http.StatusOK, "0", ""},
+		{"same user from another address", http.MethodGet, "/api/tags", "192.0.2.1:1234", 1, http.StatusTooManyRequests, "0", "10"},
+		{"another user", http.MethodGet, "/api/tags", "192.0.2.1:1234", 2, http.StatusOK, "0", ""},
+		{"route limit", http.MethodPost, "/api/users/login", "203.0.113.7:1234", 0, http.StatusOK, "1", ""},
+		{"route burst", http.MethodPost, "/api/users/login", "203.0.113.7:1234", 0, http.StatusOK, "0", ""},
+		{"route burst used up", http.MethodPost, "/api/users/login", "203.0.113.7:1234", 0, http.StatusTooManyRequests, "0", "2"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r.RemoteAddr = tt.remoteAddr
+		r = r.WithContext(context.WithValue(r.Context(), userContextKey, &userContext{isAuthenticated: tt.userId != 0, userId: tt.userId}))
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("%s: got RateLimit-Remaining %q, want %q", tt.name, got, tt.wantRemaining)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+			t.Errorf("%s: got Retry-After %q, want %q", tt.name, got, tt.wantRetryAfter)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package conduit
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+	"realworld.tayler.io/internal/validator"
+)
+
+const (
+	defaultRelatedLimit = 5
+	// the most related articles that can be asked for, and how many are ranked and cached
+	maxRelatedLimit = 20
+)
+
+// relatedCache keeps the ids of the articles most related to each article. Only ids are kept
+// since the rest of an article depends on who is looking at it.
+//
+// Changes to which articles there are, which are published or whose authors are deleted clear the
+// whole cache. A favorite only changes how related the articles sharing that favoriter are, so it
+// just drops the rankings of the favorited article and the rankings it is in. Articles that only
+// just came to share a favoriter with it aren't known without asking the database, so entries
+// also expire after a while to pick those up. Every change starts a new generation, so that a
+// ranking that was in progress during the change isn't kept.
+type relatedCache struct {
+	mu         sync.Mutex
+	generation uint64
+	entries    map[relatedKey]relatedEntry
+}
+
+type relatedEntry struct {
+	ids      []int
+	rankedAt time.Time
+}
+
+type relatedKey struct {
+	articleId         int
+	excludeSameAuthor bool
+}
+
+// invalidate starts a new generation, so that related articles are ranked again
+func (c *relatedCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
+
+// invalidateArticle starts a new generation, dropping the article's own rankings and any that it is in
+func (c *relatedCache) invalidateArticle(articleId int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		if key.articleId == articleId || slices.Contains(entry.ids, articleId) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// GET /api/articles/:slug/related
+func (app *Application) getRelatedArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	query := r.URL.Query()
+
+	v := validator.New()
+
+	limit := defaultRelatedLimit
+	if query.Has("limit") {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		v.Check(err == nil && limit >= 1 && limit <= maxRelatedLimit, "limit", "must be an integer between 1 and 20")
+	}
+
+	var excludeSameAuthor bool
+	if query.Has("excludeSameAuthor") {
+		var err error
+		excludeSameAuthor, err = strconv.ParseBool(query.Get("excludeSameAuthor"))
+		v.Check(err == nil, "excludeSameAuthor", "must be true or false")
+	}
+
+	if !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	userId := app.getUserContext(r).userId
+
+	article, err := app.domains.articles.GetArticleBySlug(slug, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrArticleNotFound):
+			app.serveResponseErrorNotFound(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	ids, err := app.relatedArticleIds(relatedKey{articleId: article.ArticleId, excludeSameAuthor: excludeSameAuthor})
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	// some of the articles may have been deleted or unpublished since they were ranked
+	articles, err := app.domains.articles.GetArticlesByIds(ids[:min(limit, len(ids))], userId)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSONList(w, http.StatusOK, "articles", articles, nil, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// relatedArticleIds ranks the articles related to an article, or returns them from the cache if
+// nothing has changed since they were last ranked and they haven't expired
+func (app *Application) relatedArticleIds(key relatedKey) ([]int, error) {
+	app.related.mu.Lock()
+	entry, ok := app.related.entries[key]
+	generation := app.related.generation
+	app.related.mu.Unlock()
+
+	if ok && time.Since(entry.rankedAt) < app.config.Related.CacheTTL {
+		return entry.ids, nil
+	}
+
+	ids, err := app.domains.articles.RelatedArticleIds(key.articleId, key.excludeSameAuthor, maxRelatedLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	app.related.mu.Lock()
+	defer app.related.mu.Unlock()
+
+	// a ranking started before the latest change may already be out of date, so isn't kept
+	if app.related.generation != generation || app.config.Related.CacheSize <= 0 {
+		return ids, nil
+	}
+
+	if len(app.related.entries) >= app.config.Related.CacheSize {
+		for key := range app.related.entries {
+			delete(app.related.entries, key)
+			break
+		}
+	}
+
+	app.related.entries[key] = relatedEntry{ids: ids, rankedAt: time.Now()}
+
+	return ids, nil
+}
@@ -2,7 +2,10 @@ package conduit
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"realworld.tayler.io/internal/validator"
 )
@@ -36,3 +39,50 @@ func (app *Application) serveResponseErrorUnprocessableEntity(w http.ResponseWri
 func (app *Application) serveResponseErrorNotFound(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 }
+
+func (app *Application) serveResponseErrorServiceUnavailable(w http.ResponseWriter, r *http.Request) {
+	msg := fmt.Sprintf("Request to %v %v refused while shutting down\n", r.Method, r.RequestURI)
+	app.logger.Warn(msg)
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+func (app *Application) serveResponseErrorTooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	msg := fmt.Sprintf("Rate limited request to %v %v from ip address: %v\n", r.Method, r.RequestURI, app.clientIp(r))
+	app.logger.Warn(msg)
+
+	headers := make(http.Header)
+	headers.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	v := validator.New()
+	v.AddError("rateLimit", "too many requests, please try again later")
+
+	err := app.writeJSON(w, http.StatusTooManyRequests, envelope{"errors": v.Errors}, headers)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+func (app *Application) serveResponseErrorPreconditionRequired(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	v.AddError("If-Match", "header is required for updates")
+
+	err := app.writeJSON(w, http.StatusPreconditionRequired, envelope{"errors": v.Errors}, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// serveResponseErrorPreconditionFailed responds with the current representation of the
+// resource, so that the client can reconcile its changes and retry against the new ETag
+func (app *Application) serveResponseErrorPreconditionFailed(w http.ResponseWriter, r *http.Request, etag string, current envelope) {
+	msg := fmt.Sprintf("Precondition failed for request to %v %v from ip address: %v\n", r.Method, r.RequestURI, app.clientIp(r))
+	app.logger.Info(msg)
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag)
+
+	err := app.writeJSON(w, http.StatusPreconditionFailed, current, headers)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
@@ -0,0 +1,192 @@
+package conduit
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"realworld.tayler.io/internal/data"
+	"realworld.tayler.io/internal/validator"
+)
+
+// GET /api/articles/:slug/revisions
+func (app *Application) getArticleRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	article, err := app.domains.articles.GetArticleBySlug(slug, app.getUserContext(r).userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrArticleNotFound):
+			app.serveResponseErrorNotFound(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	revisions, err := app.domains.articles.GetRevisions(article.ArticleId)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSONList(w, http.StatusOK, "revisions", revisions, nil, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// GET /api/articles/:slug/revisions/:id
+//
+// The diff is against the revision before, or the revision given by ?compare=
+func (app *Application) getArticleRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	v := validator.New()
+	number := readRevisionNumber(v, "id", r.PathValue("id"))
+
+	// the first revision is compared with an empty article unless told otherwise
+	var compare *int
+	if r.URL.Query().Has("compare") {
+		n := readRevisionNumber(v, "compare", r.URL.Query().Get("compare"))
+		compare = &n
+	} else if number > 1 {
+		n := number - 1
+		compare = &n
+	}
+
+	if !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	article, err := app.domains.articles.GetArticleBySlug(slug, app.getUserContext(r).userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrArticleNotFound):
+			app.serveResponseErrorNotFound(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	revision, err := app.domains.articles.GetRevision(article.ArticleId, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRevisionNotFound):
+			app.serveResponseErrorNotFound(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	var previous *data.ArticleRevision
+	if compare != nil {
+		previous, err = app.domains.articles.GetRevision(article.ArticleId, *compare)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRevisionNotFound):
+				v.AddError("compare", "revision does not exist")
+				app.serveResponseErrorUnprocessableEntity(w, v)
+			default:
+				app.serveResponseErrorInternalServerError(w, err)
+			}
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revision": revision, "diff": data.DiffRevisions(previous, revision)}, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// POST /api/articles/:slug/revisions/:id/restore
+func (app *Application) restoreArticleRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	v := validator.New()
+	number := readRevisionNumber(v, "id", r.PathValue("id"))
+	if !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	currentUserId := app.getUserContext(r).userId
+
+	article, err := app.domains.articles.GetArticleBySlug(slug, currentUserId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrArticleNotFound):
+			app.serveResponseErrorNotFound(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	if article.UserId != currentUserId {
+		app.serveResponseErrorForbidden(w, r)
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && app.config.Concurrency.RequireIfMatch {
+		app.serveResponseErrorPreconditionRequired(w, r)
+		return
+	}
+	if ifMatch != "" && !ifMatchVersion(ifMatch, article.Version) {
+		app.serveResponseErrorPreconditionFailed(w, r, articleETag(article), envelope{"article": article})
+		return
+	}
+
+	revision, err := app.domains.articles.GetRevision(article.ArticleId, number)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRevisionNotFound):
+			app.serveResponseErrorNotFound(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	restored, err := app.domains.articles.RestoreRevision(revision, article, currentUserId, article.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSlug):
+			v.AddError("slug", "duplicate slug")
+			app.serveResponseErrorUnprocessableEntity(w, v)
+		case errors.Is(err, data.ErrEditConflict):
+			// someone else got in between us reading the article and writing it back
+			current, err := app.domains.articles.GetArticleBySlug(slug, currentUserId)
+			if err != nil {
+				app.serveResponseErrorInternalServerError(w, err)
+				return
+			}
+			app.serveResponseErrorPreconditionFailed(w, r, articleETag(current), envelope{"article": current})
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, data.AuditArticleRestored, "article", restored.Slug, nil)
+
+	headers := make(http.Header)
+	headers.Set("ETag", articleETag(restored))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"article": restored}, headers)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// readRevisionNumber parses a revision number, adding an error to v when it isn't one
+func readRevisionNumber(v *validator.Validator, key, value string) int {
+	number, err := strconv.Atoi(value)
+	v.Check(err == nil && number > 0, key, "must be a positive integer")
+	return number
+}
@@ -7,35 +7,66 @@ import (
 )
 
 func (app *Application) Routes() http.Handler {
-	mux := http.NewServeMux()
+	mux := app.routes()
 
-	common := alice.New(app.recoverPanic, app.authenticateUser)
-	protected := common.Append(app.requireAuthentication)
+	// CORS has to wrap the router directly to find out which routes a preflight is for
+	return app.negotiateResponse(app.enableCORS(mux.ServeMux))
+}
+
+// routes registers every route, keeping track of their patterns so they can be checked against the spec
+func (app *Application) routes() *router {
+	mux := &router{ServeMux: http.NewServeMux()}
+
+	// request bodies are validated last, so that authentication and authorisation errors take precedence
+	base := alice.New(app.recoverPanic, app.assignRequestId, app.authenticateUser, app.rateLimit)
+	common := base.Append(app.validateRequest)
+	protected := base.Append(app.requireAuthentication, app.validateRequest)
+	admin := base.Append(app.requireAuthentication, app.requireAdmin, app.validateRequest)
+	idempotent := base.Append(app.requireAuthentication, app.validateRequest, app.idempotent)
 
 	// unauthenticated routes
 	mux.Handle("POST /api/users/login", common.ThenFunc(app.loginUserHandler))
 	mux.Handle("POST /api/users", common.ThenFunc(app.registerUserHandler))
+	mux.Handle("POST /api/users/restore", common.ThenFunc(app.restoreUserHandler))
 	mux.Handle("GET /api/articles/{slug}", common.ThenFunc(app.getArticleHandler))
 	mux.Handle("GET /api/tags", common.ThenFunc(app.getTagsHandler))
+	mux.Handle("GET /api/exports/{id}/download", common.ThenFunc(app.downloadExportHandler))
+	mux.Handle("GET /api/openapi.json", common.ThenFunc(app.getOpenAPISpecHandler))
+	if app.config.OpenAPI.SwaggerUI {
+		mux.Handle("GET /api/docs", common.ThenFunc(app.getAPIDocsHandler))
+	}
 
 	// authenticated routes
 	mux.Handle("GET /api/user", protected.ThenFunc(app.getUserHandler))
 	mux.Handle("PUT /api/user", protected.ThenFunc(app.updateUserHandler))
+	mux.Handle("DELETE /api/user", protected.ThenFunc(app.deleteUserHandler))
+	mux.Handle("GET /api/user/articles", protected.ThenFunc(app.getUserArticlesHandler))
+	mux.Handle("GET /api/user/export", protected.ThenFunc(app.exportUserHandler))
+	mux.Handle("GET /api/user/export/{id}", protected.ThenFunc(app.getUserExportHandler))
 	mux.Handle("POST /api/profiles/{username}/follow", protected.ThenFunc(app.followProfileHandler))
 	mux.Handle("DELETE /api/profiles/{username}/follow", protected.ThenFunc(app.unfollowProfileHandler))
 	mux.Handle("GET /api/articles/feed", protected.ThenFunc(app.getFeedHandler))
-	mux.Handle("POST /api/articles", protected.ThenFunc(app.createArticleHandler))
+	mux.Handle("POST /api/articles", idempotent.ThenFunc(app.createArticleHandler))
 	mux.Handle("PUT /api/articles/{slug}", protected.ThenFunc(app.updateArticleHandler))
 	mux.Handle("DELETE /api/articles/{slug}", protected.ThenFunc(app.deleteArticleHandler))
-	mux.Handle("POST /api/articles/{slug}/comments", protected.ThenFunc(app.addArticleCommentHandler))
+	mux.Handle("POST /api/articles/{slug}/comments", idempotent.ThenFunc(app.addArticleCommentHandler))
 	mux.Handle("DELETE /api/articles/{slug}/comments/{id}", protected.ThenFunc(app.deleteArticleCommentHandler))
 	mux.Handle("POST /api/articles/{slug}/favorite", protected.ThenFunc(app.favoriteArticleHandler))
 	mux.Handle("DELETE /api/articles/{slug}/favorite", protected.ThenFunc(app.unfavoriteArticleHandler))
+	mux.Handle("POST /api/articles/{slug}/revisions/{id}/restore", idempotent.ThenFunc(app.restoreArticleRevisionHandler))
+
+	// admin routes
+	mux.Handle("GET /api/admin/audit", admin.ThenFunc(app.getAuditEventsHandler))
 
 	// authentication optional routes
 	mux.Handle("GET /api/profiles/{username}", common.ThenFunc(app.getProfileHandler))
 	mux.Handle("GET /api/articles", common.ThenFunc(app.getArticlesHandler))
+	mux.Handle("GET /api/articles/search", common.ThenFunc(app.searchArticlesHandler))
+	mux.Handle("GET /api/articles/trending", common.ThenFunc(app.getTrendingArticlesHandler))
 	mux.Handle("GET /api/articles/{slug}/comments", common.ThenFunc(app.getArticleCommentsHandler))
+	mux.Handle("GET /api/articles/{slug}/revisions", common.ThenFunc(app.getArticleRevisionsHandler))
+	mux.Handle("GET /api/articles/{slug}/revisions/{id}", common.ThenFunc(app.getArticleRevisionHandler))
+	mux.Handle("GET /api/articles/{slug}/related", common.ThenFunc(app.getRelatedArticlesHandler))
 
 	return mux
 }
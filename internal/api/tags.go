@@ -4,12 +4,23 @@ import "net/http"
 
 // GET /api/tags
 func (app *Application) getTagsHandler(w http.ResponseWriter, r *http.Request) {
+	// looked up first, so that a change in between makes it older than the tags rather than newer
+	modifiedAt, err := app.domains.tags.GetTagsModifiedAt()
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
 	tags, err := app.domains.tags.GetAllTags()
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
 		return
 	}
 
+	if app.serveNotModified(w, r, tagsETag(tags), modifiedAt) {
+		return
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
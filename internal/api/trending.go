@@ -0,0 +1,76 @@
+package conduit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+	"realworld.tayler.io/internal/validator"
+)
+
+// GET /api/articles/trending
+func (app *Application) getTrendingArticlesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := &data.TrendingFilters{}
+
+	if filters.ParseFilters(v, r); !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	articles, err := app.domains.articles.GetTrendingArticles(filters, app.getUserContext(r).userId)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	countLimit := app.articleCountLimit(&filters.PaginationFilters)
+	count, err := app.domains.articles.CountTrending(filters, countLimit)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	err = app.writeJSONList(w, http.StatusOK, "articles", articles, articleListEnvelope(count, countLimit, &data.PageCursors{}), nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// recordArticleView counts the request towards the article trending, unless it was made by its
+// author. Failing to count a view isn't worth failing the request over, so errors are only logged.
+func (app *Application) recordArticleView(r *http.Request, article *data.Article) {
+	user := app.getUserContext(r)
+	if article.Status != data.ArticleStatusPublished || user.userId == article.UserId {
+		return
+	}
+
+	// anonymous viewers are told apart by their address, which is hashed so that it isn't stored
+	var viewer string
+	if user.isAuthenticated {
+		viewer = "user:" + strconv.Itoa(user.userId)
+	} else {
+		mac := hmac.New(sha256.New, app.viewerKey)
+		mac.Write([]byte(app.clientIp(r)))
+		viewer = "ip:" + hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+
+	err := app.domains.articles.RecordView(article.ArticleId, viewer, time.Now())
+	if err != nil {
+		app.logger.Error("failed to record article view", slog.String("slug", article.Slug), slog.String("error", err.Error()))
+	}
+}
+
+func (app *Application) computeTrendingScores() error {
+	scored, err := app.domains.articles.ComputeTrendingScores(time.Now(), app.config.Trending.Window, app.config.Trending.HalfLife)
+	if scored > 0 {
+		app.logger.Info("computed trending scores", slog.Int("articles", scored))
+	}
+
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"realworld.tayler.io/internal/data"
 	"realworld.tayler.io/internal/validator"
@@ -94,6 +95,7 @@ func (app *Application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrInvalidCredentials):
+			app.audit(r, data.AuditUserLoginFailed, "email", input.User.Email, nil)
 			app.serveResponseErrorUnauthorized(w, r)
 			return
 		default:
@@ -109,6 +111,8 @@ func (app *Application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 	}
 	user.Token = token
 
+	app.audit(r, data.AuditUserLogin, "user", user.Username, user)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
@@ -126,6 +130,11 @@ func (app *Application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	user.Token = userContext.token
 
+	// the token comes from the request rather than the user, so only the ETag, which covers it, is used
+	if app.serveNotModified(w, r, userETag(user), time.Time{}) {
+		return
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
@@ -143,6 +152,17 @@ func (app *Application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	user.Token = userContext.token
+	previousEmail := user.Email
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && app.config.Concurrency.RequireIfMatch {
+		app.serveResponseErrorPreconditionRequired(w, r)
+		return
+	}
+	if ifMatch != "" && !ifMatchVersion(ifMatch, user.Version) {
+		app.serveResponseErrorPreconditionFailed(w, r, userETag(user), envelope{"user": user})
+		return
+	}
 
 	var input struct {
 		User struct {
@@ -190,15 +210,164 @@ func (app *Application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		case errors.Is(err, data.ErrDuplicateEmail):
 			v.AddError("email", "duplicate email")
 			app.serveResponseErrorUnprocessableEntity(w, v)
+		case errors.Is(err, data.ErrEditConflict):
+			current, err := app.domains.users.GetUserById(userContext.userId)
+			if err != nil {
+				app.serveResponseErrorInternalServerError(w, err)
+				return
+			}
+			current.Token = userContext.token
+			app.serveResponseErrorPreconditionFailed(w, r, userETag(current), envelope{"user": current})
 		default:
 			app.serveResponseErrorInternalServerError(w, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if user.Email != previousEmail {
+		app.audit(r, data.AuditUserEmailChanged, "user", user.Username, nil)
+	}
+	if input.User.Password != nil {
+		app.audit(r, data.AuditUserPasswordChanged, "user", user.Username, nil)
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", userETag(user))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, headers)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+}
+
+// DELETE /api/user
+func (app *Application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		User struct {
+			Password *string `json:"password"`
+		} `json:"user"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	v := validator.New()
+	if v.Check(input.User.Password != nil && *input.User.Password != "", "password", "must be provided to confirm account deletion"); !v.Valid() {
+		app.serveResponseErrorUnprocessableEntity(w, v)
+		return
+	}
+
+	userContext := app.getUserContext(r)
+	user, err := app.domains.users.GetUserById(userContext.userId)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	matches, err := user.Password.Matches(*input.User.Password)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+	if !matches {
+		app.serveResponseErrorUnauthorized(w, r)
+		return
+	}
+
+	gracePeriod := app.config.Accounts.DeletionGracePeriod
+	if gracePeriod <= 0 {
+		err = app.domains.users.DeleteUser(user.UserId)
+		if err != nil {
+			app.serveResponseErrorInternalServerError(w, err)
+			return
+		}
+		app.related.invalidate()
+		app.renders.invalidate()
+
+		app.audit(r, data.AuditUserDeleted, "user", user.Username, nil)
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	deletedAt := time.Now().UTC()
+	err = app.domains.users.MarkUserDeleted(user.UserId, deletedAt)
 	if err != nil {
 		app.serveResponseErrorInternalServerError(w, err)
 		return
 	}
+	app.related.invalidate()
+
+	app.audit(r, data.AuditUserDeleted, "user", user.Username, nil)
+
+	deletion := envelope{
+		"deletedAt":       deletedAt,
+		"restorableUntil": deletedAt.Add(gracePeriod),
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"deletion": deletion}, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
+}
+
+// POST /api/users/restore
+func (app *Application) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		User struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		} `json:"user"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+
+	user, err := app.domains.users.GetDeletedUserByCredentials(input.User.Email, input.User.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCredentials):
+			app.serveResponseErrorUnauthorized(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+
+	notBefore := time.Now().Add(-app.config.Accounts.DeletionGracePeriod)
+	err = app.domains.users.RestoreUser(user.UserId, notBefore)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUserNotFound):
+			// the grace period has lapsed and the account is only waiting to be purged
+			app.serveResponseErrorNotFound(w, r)
+		default:
+			app.serveResponseErrorInternalServerError(w, err)
+		}
+		return
+	}
+	app.related.invalidate()
+
+	app.audit(r, data.AuditUserRestored, "user", user.Username, user)
+
+	token, err := app.tokenService.CreateToken(user)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+		return
+	}
+	user.Token = token
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serveResponseErrorInternalServerError(w, err)
+	}
 }
@@ -0,0 +1,77 @@
+package conduit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"realworld.tayler.io/internal/data"
+)
+
+func TestRestoredUserTokens(t *testing.T) {
+	app := newTestApplication(t)
+	app.tokenService = data.JwtTokenService{SecretKey: []byte("secret")}
+	app.config.Accounts.DeletionGracePeriod = time.Hour
+
+	credentials := `{'user':{'email':'jake@example.com','password':'jakejake'}}`
+
+	token := func(handler http.HandlerFunc, body string) string {
+		t.Helper()
+		w := serveAs(handler, jsonRequest(http.MethodPost, "/api/users", body), 0)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+		var response struct {
+			User struct {
+				Token string `json:"token"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.User.Token
+	}
+
+	authenticated := func(token string) bool {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+		r.Header.Set("Authorization", "Token "+token)
+
+		var isAuthenticated bool
+		app.authenticateUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			isAuthenticated = app.getUserContext(r).isAuthenticated
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		return isAuthenticated
+	}
+
+	registered := token(app.registerUserHandler, `{'user':{'username':'jake','email':'jake@example.com','password':'jakejake'}}`)
+	loggedIn := token(app.loginUserHandler, credentials)
+	if !authenticated(registered) || !authenticated(loggedIn) {
+		t.Fatal("tokens aren't honoured before the account is deleted")
+	}
+
+	var userId int
+	if err := app.domains.users.DB.QueryRow(`SELECT UserId FROM User WHERE Username = 'jake'`).Scan(&userId); err != nil {
+		t.Fatal(err)
+	}
+	w := serveAs(app.deleteUserHandler, jsonRequest(http.MethodDelete, "/api/user", `{'user':{'password':'jakejake'}}`), userId)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got status %d deleting the account: %s", w.Code, w.Body)
+	}
+	if authenticated(loggedIn) {
+		t.Error("token is honoured while the account is pending deletion")
+	}
+
+	restored := token(app.restoreUserHandler, credentials)
+	if authenticated(registered) || authenticated(loggedIn) {
+		t.Error("token issued before the account was deleted is honoured after it was restored")
+	}
+	if !authenticated(restored) {
+		t.Error("token issued when the account was restored isn't honoured")
+	}
+	if !authenticated(token(app.loginUserHandler, credentials)) {
+		t.Error("token issued after the account was restored isn't honoured")
+	}
+}
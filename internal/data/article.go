@@ -3,10 +3,12 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -17,37 +19,80 @@ import (
 var (
 	ErrArticleNotFound = errors.New("article not found")
 	ErrDuplicateSlug   = errors.New("duplicate slug")
+	// ErrEditConflict is returned when an update was based on a version that has since been changed
+	ErrEditConflict = errors.New("edit conflict")
 )
 
 type ArticleFilters struct {
-	Tag       *string
-	Author    *string
+	// articles with all of these tags, or any of them when TagMode is "any"
+	Tags    []string
+	TagMode string
+	// articles with none of these tags
+	ExcludeTags []string
+	// articles by any of these authors
+	Authors   []string
 	Favorited *string
+	// CreatedAfter and CreatedBefore are exclusive, UpdatedSince is inclusive
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedSince  *time.Time
 	PaginationFilters
 }
 
 type PaginationFilters struct {
 	Limit  int
 	Offset int
+	// pages from a cursor instead of an offset when set
+	Cursor *Cursor
+	// count up to a limit rather than counting every matching article
+	EstimateCount bool
+	// one of the keys of articleOrders, defaults to newest
+	Order string
+	// when counted orders are counted at, nil to count everything there is
+	AsOf *time.Time
 }
 
+// only published articles are visible to anyone other than their author
+const (
+	ArticleStatusDraft     = "draft"
+	ArticleStatusScheduled = "scheduled"
+	ArticleStatusPublished = "published"
+)
+
+var articleStatuses = []string{ArticleStatusDraft, ArticleStatusScheduled, ArticleStatusPublished}
+
 type BodylessArticle struct {
-	ArticleId      int       `json:"-"`
-	UserId         int       `json:"-"`
-	Slug           string    `json:"slug"`
-	Title          string    `json:"title"`
-	Description    string    `json:"description"`
-	TagList        []string  `json:"tagList"`
-	CreatedAt      time.Time `json:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt"`
-	Favorited      bool      `json:"favorited"`
-	FavoritesCount int       `json:"favoritesCount"`
-	Author         *Profile  `json:"author"`
+	ArticleId   int       `json:"-"`
+	UserId      int       `json:"-"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	TagList     []string  `json:"tagList"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	// one of ArticleStatusDraft, ArticleStatusScheduled or ArticleStatusPublished
+	Status string `json:"status"`
+	// when the article is due to be published, or when it was, nil for drafts
+	PublishAt *time.Time `json:"publishAt"`
+	// worked out from the body when the article is saved, see readingStats
+	WordCount          int `json:"wordCount"`
+	ReadingTimeMinutes int `json:"readingTimeMinutes"`
+	// the start of the body, when the description is too short to say what the article is about
+	Excerpt        *string  `json:"excerpt"`
+	Favorited      bool     `json:"favorited"`
+	FavoritesCount int      `json:"favoritesCount"`
+	Author         *Profile `json:"author"`
+
+	// the value the article was sorted on in a list, used for the cursors either side of the page
+	sortKey float64
 }
 
 type Article struct {
 	BodylessArticle
-	Body string `json:"body"`
+	Body    string `json:"body"`
+	Version int    `json:"-"`
+	// when anything in the article or its author last changed, zero if that isn't known
+	ModifiedAt time.Time `json:"-"`
 }
 
 type CreateArticleDTO struct {
@@ -56,6 +101,9 @@ type CreateArticleDTO struct {
 		Description *string  `json:"description"`
 		Body        *string  `json:"body"`
 		TagList     []string `json:"tagList"`
+		// defaults to published, or to scheduled when PublishAt is set
+		Status    *string    `json:"status"`
+		PublishAt *time.Time `json:"publishAt"`
 	} `json:"article"`
 }
 
@@ -64,29 +112,99 @@ type UpdateArticleDTO struct {
 		Title       *string `json:"title"`
 		Description *string `json:"description"`
 		Body        *string `json:"body"`
+		// replaces every tag, an empty list removes them all
+		TagList *[]string `json:"tagList"`
+		// change the tags the article already has, can't be used together with TagList
+		AddTags    []string `json:"addTags"`
+		RemoveTags []string `json:"removeTags"`
+		// the article's status is left alone when neither is set
+		Status    *string    `json:"status"`
+		PublishAt *time.Time `json:"publishAt"`
 	} `json:"article"`
 }
 
 func (f *ArticleFilters) ParseFilters(v *validator.Validator, r *http.Request) {
-	if r.URL.Query().Has("tag") {
-		value := r.URL.Query().Get("tag")
-		f.Tag = &value
-		v.Check(*f.Tag != "", "tag", "must not be blank")
-	}
-	if r.URL.Query().Has("author") {
-		value := r.URL.Query().Get("author")
-		f.Author = &value
-		v.Check(*f.Author != "", "author", "must not be blank")
-	}
-	if r.URL.Query().Has("favorited") {
-		value := r.URL.Query().Get("favorited")
+	query := r.URL.Query()
+
+	for key, dst := range map[string]*[]string{
+		"tag":        &f.Tags,
+		"excludeTag": &f.ExcludeTags,
+		"author":     &f.Authors,
+	} {
+		for _, value := range query[key] {
+			v.Check(value != "", key, "must not be blank")
+			if !slices.Contains(*dst, value) {
+				*dst = append(*dst, value)
+			}
+		}
+	}
+
+	f.TagMode = "all"
+	if query.Has("tagMode") {
+		f.TagMode = query.Get("tagMode")
+		v.Check(f.TagMode == "all" || f.TagMode == "any", "tagMode", "must be all or any")
+	}
+
+	if query.Has("favorited") {
+		value := query.Get("favorited")
 		f.Favorited = &value
 		v.Check(*f.Favorited != "", "favorited", "must not be blank")
 	}
+
+	for key, dst := range map[string]**time.Time{
+		"createdAfter":  &f.CreatedAfter,
+		"createdBefore": &f.CreatedBefore,
+		"updatedSince":  &f.UpdatedSince,
+	} {
+		if query.Has(key) {
+			value, err := time.Parse(time.RFC3339, query.Get(key))
+			if err != nil {
+				v.AddError(key, "must be an RFC 3339 timestamp")
+			} else {
+				*dst = &value
+			}
+		}
+	}
+
+	if f.CreatedAfter != nil && f.CreatedBefore != nil {
+		v.Check(f.CreatedAfter.Before(*f.CreatedBefore), "createdBefore", "must be after createdAfter")
+	}
+
 	f.PaginationFilters = PaginationFilters{}
 	f.PaginationFilters.ParseFilters(v, r)
 }
 
+// args are the named parameters of filteredArticles
+func (f *ArticleFilters) args() []any {
+	// how many of the tags an article needs to match
+	tagsRequired := len(f.Tags)
+	if f.TagMode == "any" {
+		tagsRequired = 1
+	}
+
+	return []any{
+		sql.Named("authors", jsonArray(f.Authors)),
+		sql.Named("favorited", f.Favorited),
+		sql.Named("tags", jsonArray(f.Tags)),
+		sql.Named("tagsRequired", tagsRequired),
+		sql.Named("excludeTags", jsonArray(f.ExcludeTags)),
+		sql.Named("createdAfter", formatOptionalTime(f.CreatedAfter)),
+		sql.Named("createdBefore", formatOptionalTime(f.CreatedBefore)),
+		sql.Named("updatedSince", formatOptionalTime(f.UpdatedSince)),
+	}
+}
+
+// jsonArray encodes a list for json_each, so that any number of values can be bound to a single
+// parameter. An empty list is nil, to switch off the filter it belongs to.
+func jsonArray(values []string) *string {
+	if len(values) == 0 {
+		return nil
+	}
+	raw, _ := json.Marshal(values)
+	encoded := string(raw)
+	return &encoded
+}
+
 func (f *PaginationFilters) ParseFilters(v *validator.Validator, r *http.Request) {
 	if r.URL.Query().Has("limit") {
 		value := r.URL.Query().Get("limit")
@@ -112,6 +230,44 @@ func (f *PaginationFilters) ParseFilters(v *validator.Validator, r *http.Request
 		f.Offset = 0
 	}
 
+	f.Order = defaultArticleOrder
+	if r.URL.Query().Has("order") {
+		f.Order = r.URL.Query().Get("order")
+		_, ok := articleOrders[f.Order]
+		v.Check(ok, "order", "must be one of newest, oldest, updated, favorited or commented")
+	}
+
+	if r.URL.Query().Has("cursor") {
+		cursor, err := DecodeCursor(r.URL.Query().Get("cursor"))
+		if err != nil {
+			v.AddError("cursor", "is invalid")
+		} else {
+			f.Cursor = cursor
+			if r.URL.Query().Has("order") {
+				v.Check(cursor.Order == f.Order, "cursor", "is for a different order")
+			} else {
+				f.Order = cursor.Order
+			}
+		}
+		v.Check(!r.URL.Query().Has("offset"), "offset", "must not be used together with cursor")
+	}
+
+	// counted orders keep counting as of the first page for every page after it
+	if articleOrders[f.Order].counted {
+		if f.Cursor != nil && f.Cursor.AsOf != nil {
+			f.AsOf = f.Cursor.AsOf
+		} else {
+			now := time.Now()
+			f.AsOf = &now
+		}
+	}
+
+	if r.URL.Query().Has("count") {
+		value := r.URL.Query().Get("count")
+		v.Check(value == "exact" || value == "estimate", "count", "must be exact or estimate")
+		f.EstimateCount = value == "estimate"
+	}
+
 	v.Check(f.Limit > 0, "limit", "must be a positive integer")
 	v.Check(f.Offset >= 0, "offset", "must be greater than or equal to zero")
 }
@@ -120,17 +276,20 @@ func (article CreateArticleDTO) Validate(v *validator.Validator) {
 	v.Check(article.Article.Body != nil && *article.Article.Body != "", "body", "must not be empty")
 	v.Check(article.Article.Description != nil && *article.Article.Description != "", "description", "must not be empty")
 	v.Check(article.Article.Title != nil && *article.Article.Title != "", "title", "must not be empty")
-	for _, tag := range article.Article.TagList {
-		v.Check(tag != "", "tagList", "tag must not be blank")
-		v.Check(!strings.Contains(tag, ","), "tagList", "must not contain ','")
-	}
+	validateTags(v, "tagList", article.Article.TagList)
+	validatePublishing(v, article.Article.Status, article.Article.PublishAt)
 }
 
 func (article UpdateArticleDTO) Validate(v *validator.Validator) {
 
 	v.Check(article.Article.Body != nil ||
 		article.Article.Description != nil ||
-		article.Article.Title != nil, "article", "must provide at least one of body, description or title")
+		article.Article.Title != nil ||
+		article.Article.TagList != nil ||
+		len(article.Article.AddTags) > 0 ||
+		len(article.Article.RemoveTags) > 0 ||
+		article.Article.Status != nil ||
+		article.Article.PublishAt != nil, "article", "must provide at least one of body, description, title, tagList, addTags, removeTags, status or publishAt")
 
 	if article.Article.Body != nil {
 		v.Check(*article.Article.Body != "", "body", "must not be blank")
@@ -141,14 +300,43 @@ func (article UpdateArticleDTO) Validate(v *validator.Validator) {
 	if article.Article.Title != nil {
 		v.Check(*article.Article.Title != "", "title", "must not be blank")
 	}
+
+	if article.Article.TagList != nil {
+		validateTags(v, "tagList", *article.Article.TagList)
+		v.Check(len(article.Article.AddTags) == 0, "addTags", "must not be used together with tagList")
+		v.Check(len(article.Article.RemoveTags) == 0, "removeTags", "must not be used together with tagList")
+	}
+	validateTags(v, "addTags", article.Article.AddTags)
+	validateTags(v, "removeTags", article.Article.RemoveTags)
+	for _, tag := range article.Article.AddTags {
+		v.Check(!slices.Contains(article.Article.RemoveTags, tag), "removeTags", "must not contain tags that are in addTags")
+	}
 }
 
-func (article CreateArticleDTO) GetSlug() string {
-	return strings.ToLower(strings.ReplaceAll(*article.Article.Title, " ", "-"))
+func parseOptionalTime(raw *string) (*time.Time, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, *raw)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
 }
 
-func (article UpdateArticleDTO) GetSlug() string {
-	return strings.ToLower(strings.ReplaceAll(*article.Article.Title, " ", "-"))
+// parseModifiedAt parses a ModifiedAt column, returning the zero time for NULL
+func parseModifiedAt(raw *string) (time.Time, error) {
+	if raw == nil {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, *raw)
+}
+
+func validateTags(v *validator.Validator, key string, tags []string) {
+	for _, tag := range tags {
+		v.Check(tag != "", key, "tag must not be blank")
+		v.Check(!strings.Contains(tag, ","), key, "must not contain ','")
+	}
 }
 
 type ArticleRepository struct {
@@ -160,20 +348,31 @@ type ArticleRepository struct {
 func (repo *ArticleRepository) CreateArticle(articleDto CreateArticleDTO, userId int) (article *Article, retErr error) {
 
 	query := `INSERT INTO Article 
-				(UserId, Slug, Title, Description, Body, CreatedAt, UpdatedAt) 
-				VALUES ($1, $2, $3, $4, $5, $6, $7) 
+				(UserId, Slug, Title, Description, Body, CreatedAt, UpdatedAt, Status, PublishAt, WordCount, ReadingTimeMinutes, Excerpt) 
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) 
 				RETURNING ArticleId`
 
-	now := time.Now().UTC().Format(time.RFC3339Nano)
+	createdAt := time.Now()
+	now := createdAt.UTC().Format(time.RFC3339Nano)
+	status, publishAt := publishing(articleDto.Article.Status, articleDto.Article.PublishAt, createdAt)
+	words, readingTime, excerpt := readingStats(*articleDto.Article.Description, *articleDto.Article.Body)
+
+	baseSlug := Slugify(*articleDto.Article.Title)
+	slug := unreservedSlug(baseSlug)
 
 	args := []any{
 		userId,
-		articleDto.GetSlug(),
+		slug,
 		*articleDto.Article.Title,
 		*articleDto.Article.Description,
 		*articleDto.Article.Body,
 		now,
 		now,
+		status,
+		formatOptionalTime(publishAt),
+		words,
+		readingTime,
+		excerpt,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
@@ -193,10 +392,18 @@ func (repo *ArticleRepository) CreateArticle(articleDto CreateArticleDTO, userId
 		}
 	}()
 
+	// another article already having the slug isn't an error, the slug is just made unique
 	var articleId int
-	err = tx.QueryRowContext(ctx, query, args...).Scan(&articleId)
-	if err != nil {
+	for attempt := 1; ; attempt++ {
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&articleId)
+		if err == nil {
+			break
+		}
+
 		switch {
+		case err.Error() == "UNIQUE constraint failed: Article.Slug" && attempt < maxSlugAttempts:
+			slug = withSlugSuffix(baseSlug)
+			args[1] = slug
 		case err.Error() == "UNIQUE constraint failed: Article.Slug":
 			retErr = ErrDuplicateSlug
 			return nil, retErr
@@ -206,27 +413,16 @@ func (repo *ArticleRepository) CreateArticle(articleDto CreateArticleDTO, userId
 		}
 	}
 
-	for _, tag := range articleDto.Article.TagList {
-		selectTagIdQuery := `SELECT t.TagId FROM Tag t WHERE t.Tag = $1`
-		var tagId int
-
-		err = tx.QueryRowContext(ctx, selectTagIdQuery, tag).Scan(&tagId)
-		if err == sql.ErrNoRows {
-
-			insertTagQuery := `INSERT INTO Tag (Tag) VALUES ($1) RETURNING TagId`
-			err = tx.QueryRowContext(ctx, insertTagQuery, tag).Scan(&tagId)
-			if err != nil {
-				retErr = fmt.Errorf("an error occurred when attempting to save a tag: %w", err)
-				return nil, retErr
-			}
-		}
+	err = addArticleTags(ctx, tx, articleId, articleDto.Article.TagList)
+	if err != nil {
+		retErr = err
+		return nil, retErr
+	}
 
-		insertArticleTagQuery := `INSERT OR IGNORE INTO ArticleTag (ArticleId, TagId) VALUES ($1, $2)`
-		_, err = tx.ExecContext(ctx, insertArticleTagQuery, articleId, tagId)
-		if err != nil {
-			retErr = fmt.Errorf("an error occurred when attempting to tag an article: %w", err)
-			return nil, retErr
-		}
+	err = addArticleRevision(ctx, tx, articleId, nil)
+	if err != nil {
+		retErr = err
+		return nil, retErr
 	}
 
 	err = tx.Commit()
@@ -235,7 +431,7 @@ func (repo *ArticleRepository) CreateArticle(articleDto CreateArticleDTO, userId
 		return nil, retErr
 	}
 
-	article, err = repo.GetArticleBySlug(articleDto.GetSlug(), userId)
+	article, err = repo.GetArticleBySlug(slug, userId)
 	if err != nil {
 		retErr = fmt.Errorf("an error occurred when looking up article by slug after saving: %w", err)
 		return nil, retErr
@@ -244,49 +440,238 @@ func (repo *ArticleRepository) CreateArticle(articleDto CreateArticleDTO, userId
 	return article, retErr
 }
 
-func (repo *ArticleRepository) UpdateArticle(articleDto UpdateArticleDTO, articleId, userId int) (*Article, error) {
+// UpdateArticle only applies the update if the article is still at expectedVersion,
+// otherwise ErrEditConflict is returned and nothing is changed
+func (repo *ArticleRepository) UpdateArticle(articleDto UpdateArticleDTO, articleId, userId, expectedVersion int) (*Article, error) {
+	return repo.updateArticle(articleDto, articleId, userId, expectedVersion, nil)
+}
+
+// updateArticle saves the update along with a revision, which is marked as restored from an
+// older revision when restoredFrom is set
+func (repo *ArticleRepository) updateArticle(articleDto UpdateArticleDTO, articleId, userId, expectedVersion int, restoredFrom *int) (article *Article, retErr error) {
+
+	slug, err := repo.slugForUpdate(articleId, *articleDto.Article.Title)
+	if err != nil {
+		return nil, err
+	}
+	baseSlug := Slugify(*articleDto.Article.Title)
 
 	query := `UPDATE Article 
 			  SET Slug = $1,
 			      Title = $2,
 				  Description = $3,
 				  Body = $4,
-				  UpdatedAt = $5
-			  WHERE ArticleId = $6 AND UserId = $7`
-
-	now := time.Now().UTC().Format(time.RFC3339Nano)
+				  UpdatedAt = $5,
+				  Status = $6,
+				  PublishAt = $7,
+				  WordCount = $8,
+				  ReadingTimeMinutes = $9,
+				  Excerpt = $10,
+				  Version = Version + 1
+			  WHERE ArticleId = $11 AND UserId = $12 AND Version = $13`
+
+	updatedAt := time.Now()
+	now := updatedAt.UTC().Format(time.RFC3339Nano)
+	status, publishAt := publishing(articleDto.Article.Status, articleDto.Article.PublishAt, updatedAt)
+	words, readingTime, excerpt := readingStats(*articleDto.Article.Description, *articleDto.Article.Body)
 
 	args := []any{
-		articleDto.GetSlug(),
+		slug,
 		*articleDto.Article.Title,
 		*articleDto.Article.Description,
 		*articleDto.Article.Body,
 		now,
+		status,
+		formatOptionalTime(publishAt),
+		words,
+		readingTime,
+		excerpt,
 		articleId,
 		userId,
+		expectedVersion,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
 
-	_, err := repo.DB.ExecContext(ctx, query, args...)
+	tx, err := repo.DB.BeginTx(ctx, nil)
 	if err != nil {
+		retErr = fmt.Errorf("an error occurred when starting a transaction while attempting to save an article: %w", err)
+		return nil, retErr
+	}
+	defer func() {
+		if retErr != nil {
+			err = tx.Rollback()
+			if err != nil && !errors.Is(err, sql.ErrTxDone) {
+				repo.Log.ErrorContext(ctx, err.Error())
+			}
+		}
+	}()
+
+	var result sql.Result
+	for attempt := 1; ; attempt++ {
+		result, err = tx.ExecContext(ctx, query, args...)
+		if err == nil {
+			break
+		}
+
 		switch {
+		case err.Error() == "UNIQUE constraint failed: Article.Slug" && attempt < maxSlugAttempts:
+			slug = withSlugSuffix(baseSlug)
+			args[0] = slug
 		case err.Error() == "UNIQUE constraint failed: Article.Slug":
-			return nil, ErrDuplicateSlug
+			retErr = ErrDuplicateSlug
+			return nil, retErr
 		default:
-			return nil, fmt.Errorf("an error occurred when saving article: %w", err)
+			retErr = fmt.Errorf("an error occurred when saving article: %w", err)
+			return nil, retErr
+		}
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		retErr = fmt.Errorf("an error occurred when saving article: %w", err)
+		return nil, retErr
+	} else if rows == 0 {
+		retErr = ErrEditConflict
+		return nil, retErr
+	}
+
+	err = updateArticleTags(ctx, tx, articleId, articleDto)
+	if err != nil {
+		retErr = err
+		return nil, retErr
+	}
+
+	err = addArticleRevision(ctx, tx, articleId, restoredFrom)
+	if err != nil {
+		retErr = err
+		return nil, retErr
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when attempting to commit the transaction while saving an article: %w", err)
+		return nil, retErr
+	}
+
+	article, err = repo.GetArticleBySlug(slug, userId)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when looking up article by slug after saving: %w", err)
+		return nil, retErr
+	}
+
+	return article, retErr
+}
+
+// updateArticleTags applies the tag changes in an update, if there are any. Tags no article
+// uses any more are deleted, the same as when a user's articles are deleted.
+func updateArticleTags(ctx context.Context, tx *sql.Tx, articleId int, articleDto UpdateArticleDTO) error {
+	tags := articleDto.Article
+	if tags.TagList == nil && len(tags.AddTags) == 0 && len(tags.RemoveTags) == 0 {
+		return nil
+	}
+
+	if tags.TagList != nil {
+		_, err := tx.ExecContext(ctx, `DELETE FROM ArticleTag WHERE ArticleId = $1`, articleId)
+		if err != nil {
+			return fmt.Errorf("an error occurred when attempting to remove an article's tags: %w", err)
+		}
+	}
+
+	removeArticleTagQuery := `DELETE FROM ArticleTag
+							  WHERE ArticleId = $1 AND TagId = (SELECT TagId FROM Tag WHERE Tag = $2)`
+	for _, tag := range tags.RemoveTags {
+		_, err := tx.ExecContext(ctx, removeArticleTagQuery, articleId, tag)
+		if err != nil {
+			return fmt.Errorf("an error occurred when attempting to remove a tag from an article: %w", err)
+		}
+	}
+
+	addTags := tags.AddTags
+	if tags.TagList != nil {
+		addTags = *tags.TagList
+	}
+	err := addArticleTags(ctx, tx, articleId, addTags)
+	if err != nil {
+		return err
+	}
+
+	deleteOrphanedTagsQuery := `DELETE FROM Tag WHERE NOT EXISTS (SELECT 1 FROM ArticleTag at WHERE at.TagId = Tag.TagId)`
+	_, err = tx.ExecContext(ctx, deleteOrphanedTagsQuery)
+	if err != nil {
+		return fmt.Errorf("an error occurred when attempting to delete unused tags: %w", err)
+	}
+
+	return nil
+}
+
+// addArticleTags tags an article, ignoring any tags it already has
+func addArticleTags(ctx context.Context, tx *sql.Tx, articleId int, tags []string) error {
+	insertArticleTagQuery := `INSERT OR IGNORE INTO ArticleTag (ArticleId, TagId) VALUES ($1, $2)`
+
+	for _, tag := range tags {
+		tagId, err := findOrCreateTag(ctx, tx, tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, insertArticleTagQuery, articleId, tagId)
+		if err != nil {
+			return fmt.Errorf("an error occurred when attempting to tag an article: %w", err)
 		}
 	}
 
-	article, err := repo.GetArticleBySlug(articleDto.GetSlug(), userId)
+	return nil
+}
+
+func findOrCreateTag(ctx context.Context, tx *sql.Tx, tag string) (int, error) {
+	selectTagIdQuery := `SELECT t.TagId FROM Tag t WHERE t.Tag = $1`
+	insertTagQuery := `INSERT INTO Tag (Tag) VALUES ($1) RETURNING TagId`
+
+	var tagId int
+	err := tx.QueryRowContext(ctx, selectTagIdQuery, tag).Scan(&tagId)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = tx.QueryRowContext(ctx, insertTagQuery, tag).Scan(&tagId)
+		if err != nil {
+			return 0, fmt.Errorf("an error occurred when attempting to save a tag: %w", err)
+		}
+	case err != nil:
+		return 0, fmt.Errorf("an error occurred when looking up a tag: %w", err)
+	}
+
+	return tagId, nil
+}
+
+// slugForUpdate works out the slug for an article being given a title. The slug is kept as it
+// is unless the new title would give a different one, so that links to the article keep working
+// through edits that don't touch the title or only change its punctuation or case.
+func (repo *ArticleRepository) slugForUpdate(articleId int, title string) (string, error) {
+	query := `SELECT Slug FROM Article WHERE ArticleId = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var current string
+	err := repo.DB.QueryRowContext(ctx, query, articleId).Scan(&current)
 	if err != nil {
-		return nil, fmt.Errorf("an error occurred when looking up article by slug after saving: %w", err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrArticleNotFound
+		default:
+			return "", fmt.Errorf("error looking up current slug: %w", err)
+		}
 	}
 
-	return article, nil
+	slug := Slugify(title)
+	if current == slug || hasSlugSuffix(current, slug) {
+		return current, nil
+	}
+	return unreservedSlug(slug), nil
 }
 
+// GetArticleBySlug also finds articles by a slug they had before their title changed, in which
+// case the article's Slug is its current one rather than the one that was asked for. Articles
+// that haven't been published yet are only found for their author.
 func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Article, error) {
 	query := `SELECT
 				a.ArticleId,
@@ -297,6 +682,12 @@ func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Artic
 				a.Body,
 				a.CreatedAt,
 				a.UpdatedAt,
+				a.Status,
+				a.PublishAt,
+				COALESCE(a.WordCount, 0),
+				a.ReadingTimeMinutes,
+				a.Excerpt,
+				a.Version,
 				(SELECT EXISTS(SELECT 1 FROM ArticleFavorite af WHERE af.ArticleId=a.ArticleId AND af.UserId=$1)) AS Favorited,
 				(SELECT COUNT(*) FROM ArticleFavorite af WHERE af.ArticleId=a.ArticleId) AS FavoritesCount,
 				COALESCE((SELECT GROUP_CONCAT(t.Tag, ',') 
@@ -306,10 +697,14 @@ func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Artic
 				EXISTS (SELECT 1 FROM Follower WHERE UserId = $1 AND FollowUserId = a.UserId) AS Following,
 				u.Username,
 				u.Bio,
-				u.Image
+				u.Image,
+				a.ModifiedAt,
+				u.ModifiedAt
 			  FROM Article a
 			  JOIN User u ON a.UserId = u.UserId 
-			  WHERE a.Slug = $2`
+			  WHERE (a.Slug = $2 OR a.ArticleId = (SELECT ArticleId FROM ArticleSlugHistory WHERE Slug = $2))
+			  AND (a.Status = 'published' OR a.UserId = $1)
+			  AND u.DeletedAt IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
@@ -319,6 +714,9 @@ func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Artic
 	var rawTags string
 	var createdAt string
 	var updatedAt string
+	var publishAt *string
+	var articleModifiedAt *string
+	var authorModifiedAt *string
 
 	err := repo.DB.QueryRowContext(ctx, query, userId, slug).Scan(
 		&article.ArticleId,
@@ -329,6 +727,12 @@ func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Artic
 		&article.Body,
 		&createdAt,
 		&updatedAt,
+		&article.Status,
+		&publishAt,
+		&article.WordCount,
+		&article.ReadingTimeMinutes,
+		&article.Excerpt,
+		&article.Version,
 		&article.Favorited,
 		&article.FavoritesCount,
 		&rawTags,
@@ -336,6 +740,8 @@ func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Artic
 		&author.Username,
 		&author.Bio,
 		&author.Image,
+		&articleModifiedAt,
+		&authorModifiedAt,
 	)
 	if err != nil {
 		switch {
@@ -356,6 +762,26 @@ func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Artic
 		return nil, fmt.Errorf("error parsing updated at date: %w", err)
 	}
 
+	article.PublishAt, err = parseOptionalTime(publishAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing publish at date: %w", err)
+	}
+
+	// the author is part of the article, so whichever of the two changed last
+	article.ModifiedAt, err = parseModifiedAt(articleModifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing modified at date: %w", err)
+	}
+	authorModified, err := parseModifiedAt(authorModifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing modified at date: %w", err)
+	}
+	if article.ModifiedAt.IsZero() || authorModified.IsZero() {
+		article.ModifiedAt = time.Time{}
+	} else if authorModified.After(article.ModifiedAt) {
+		article.ModifiedAt = authorModified
+	}
+
 	article.Author = &author
 
 	if rawTags == "" {
@@ -367,45 +793,156 @@ func (repo *ArticleRepository) GetArticleBySlug(slug string, userId int) (*Artic
 	return &article, nil
 }
 
-func (repo *ArticleRepository) GetArticles(filters *ArticleFilters, userId int) ([]*BodylessArticle, error) {
-	articles := make([]*BodylessArticle, 0)
-
-	query := `SELECT DISTINCT
+// bodylessArticleColumns are the columns of every query that lists articles, read back by
+// scanBodylessArticle. Favorited and Following are for the user in @userId, so queries using
+// them have to bind it.
+const bodylessArticleColumns = `
 				a.ArticleId,
-				a.UserId, 
+				a.UserId,
 				a.Title,
 				a.Slug,
 				a.Description,
 				a.CreatedAt,
 				a.UpdatedAt,
-				(SELECT EXISTS(SELECT 1 FROM ArticleFavorite af WHERE af.ArticleId=a.ArticleId AND af.UserId=$1)) AS Favorited,
+				a.Status,
+				a.PublishAt,
+				COALESCE(a.WordCount, 0),
+				a.ReadingTimeMinutes,
+				a.Excerpt,
+				(SELECT EXISTS(SELECT 1 FROM ArticleFavorite af WHERE af.ArticleId=a.ArticleId AND af.UserId=@userId)) AS Favorited,
 				(SELECT COUNT(*) FROM ArticleFavorite af WHERE af.ArticleId=a.ArticleId) AS FavoritesCount,
-				COALESCE((SELECT GROUP_CONCAT(t.Tag, ',') 
-						FROM Tag t 
-						JOIN ArticleTag at ON at.TagId = t.TagId 
+				COALESCE((SELECT GROUP_CONCAT(t.Tag, ',')
+						FROM Tag t
+						JOIN ArticleTag at ON at.TagId = t.TagId
 						WHERE at.ArticleId = a.ArticleId), '') AS Tags,
-				EXISTS (SELECT 1 FROM Follower WHERE UserId = $1 AND FollowUserId = a.UserId) AS Following,
+				EXISTS (SELECT 1 FROM Follower WHERE UserId = @userId AND FollowUserId = a.UserId) AS Following,
 				u.Username,
 				u.Bio,
-				u.Image
+				u.Image`
+
+// scanBodylessArticle scans a row selected with bodylessArticleColumns, with any columns the
+// query selects after them scanned into extra
+func scanBodylessArticle(rows *sql.Rows, extra ...any) (*BodylessArticle, error) {
+	var article BodylessArticle
+	var author Profile
+	var rawTags string
+	var createdAt string
+	var updatedAt string
+	var publishAt *string
+
+	dest := []any{
+		&article.ArticleId,
+		&article.UserId,
+		&article.Title,
+		&article.Slug,
+		&article.Description,
+		&createdAt,
+		&updatedAt,
+		&article.Status,
+		&publishAt,
+		&article.WordCount,
+		&article.ReadingTimeMinutes,
+		&article.Excerpt,
+		&article.Favorited,
+		&article.FavoritesCount,
+		&rawTags,
+		&author.Following,
+		&author.Username,
+		&author.Bio,
+		&author.Image,
+	}
+
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning article row: %w", err)
+	}
+
+	article.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created at date: %w", err)
+	}
+
+	article.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated at date: %w", err)
+	}
+
+	article.PublishAt, err = parseOptionalTime(publishAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing publish at date: %w", err)
+	}
+
+	article.Author = &author
+
+	if rawTags == "" {
+		article.TagList = make([]string, 0)
+	} else {
+		article.TagList = strings.Split(rawTags, ",")
+	}
+
+	return &article, nil
+}
+
+// filteredArticles selects the articles matching ArticleFilters. It is shared by GetArticles and
+// CountArticles so that the count always agrees with the list, with the filters bound by name
+// from ArticleFilters.args, so that it can be combined with queries that have parameters of
+// their own. Each filter is a subquery on the article rather than a join, so that an article with
+// several tags or favorites is only selected once. Times are compared as julian days, since text
+// comparison goes wrong when fractional seconds differ in length.
+// Only published articles are ever selected, authors list their others with GetUserArticles.
+const filteredArticles = `
+			FROM Article a` + articleFilterConditions
+
+// articleFilterConditions are the joins and conditions of filteredArticles, for queries that
+// need to select from something else first
+const articleFilterConditions = `
+			JOIN User u ON a.UserId = u.UserId
+			WHERE (@authors IS NULL OR u.Username IN (SELECT value FROM json_each(@authors)))
+			AND (@favorited IS NULL OR EXISTS (
+				SELECT 1 FROM ArticleFavorite af
+				JOIN User favoriter ON favoriter.UserId = af.UserId
+				WHERE af.ArticleId = a.ArticleId AND favoriter.Username = @favorited))
+			AND (@tags IS NULL OR (
+				SELECT COUNT(*) FROM ArticleTag at
+				JOIN Tag t ON t.TagId = at.TagId
+				WHERE at.ArticleId = a.ArticleId AND t.Tag IN (SELECT value FROM json_each(@tags))) >= @tagsRequired)
+			AND (@excludeTags IS NULL OR NOT EXISTS (
+				SELECT 1 FROM ArticleTag at
+				JOIN Tag t ON t.TagId = at.TagId
+				WHERE at.ArticleId = a.ArticleId AND t.Tag IN (SELECT value FROM json_each(@excludeTags))))
+			AND (@createdAfter IS NULL OR julianday(a.CreatedAt) > julianday(@createdAfter))
+			AND (@createdBefore IS NULL OR julianday(a.CreatedAt) < julianday(@createdBefore))
+			AND (@updatedSince IS NULL OR julianday(a.UpdatedAt) >= julianday(@updatedSince))
+			AND a.Status = 'published'
+			AND u.DeletedAt IS NULL`
+
+// feedArticles selects the articles by authors the user in @userId follows, shared by GetFeed and CountFeed
+const feedArticles = `
 			FROM Article a
 			JOIN User u ON a.UserId = u.UserId
-			LEFT JOIN ArticleTag at ON at.ArticleId = a.ArticleId
-			LEFT JOIN Tag t ON t.TagId = at.TagId
-			LEFT JOIN ArticleFavorite af ON af.ArticleId = a.ArticleId
-			LEFT JOIN User favoriter ON favoriter.UserId = af.UserId
-			WHERE ($2 IS NULL OR u.Username = $2)
-			AND ($3 IS NULL OR favoriter.Username = $3)
-			AND ($4 IS NULL OR t.Tag = $4)
-			ORDER BY a.ArticleId DESC
-			LIMIT $5 OFFSET $6`
-
-	args := []any{userId,
-		filters.Author,
-		filters.Favorited,
-		filters.Tag,
-		filters.Limit,
-		filters.Offset}
+			JOIN Follower f ON a.UserId = f.FollowUserId
+			WHERE f.UserId = @userId
+			AND a.Status = 'published'
+			AND u.DeletedAt IS NULL`
+
+// GetArticles returns a page of the articles matching the filters, along with the cursors for the pages either side
+func (repo *ArticleRepository) GetArticles(filters *ArticleFilters, userId int) ([]*BodylessArticle, *PageCursors, error) {
+	articles := make([]*BodylessArticle, 0)
+
+	sortKey, keyset, orderBy, keysetArgs := filters.keyset()
+
+	query := `SELECT` + bodylessArticleColumns + `,
+				` + sortKey + ` AS SortKey
+` + filteredArticles + `
+			AND ` + keyset + `
+			ORDER BY ` + orderBy + `
+			LIMIT @limit OFFSET @offset`
+
+	args := []any{sql.Named("userId", userId)}
+	args = append(args, filters.args()...)
+	args = append(args, keysetArgs...)
+	// fetch one more than asked for to find out whether there's another page
+	args = append(args, sql.Named("limit", filters.Limit+1), sql.Named("offset", filters.Offset))
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
@@ -414,99 +951,50 @@ func (repo *ArticleRepository) GetArticles(filters *ArticleFilters, userId int)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return articles, nil
+			return articles, &PageCursors{}, nil
 		default:
-			return nil, fmt.Errorf("error querying articles while querying articles: %w", err)
+			return nil, nil, fmt.Errorf("error querying articles while querying articles: %w", err)
 		}
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
-
-		var article BodylessArticle
-		var author Profile
-		var rawTags string
-		var createdAt string
-		var updatedAt string
-
-		err = rows.Scan(
-			&article.ArticleId,
-			&article.UserId,
-			&article.Title,
-			&article.Slug,
-			&article.Description,
-			&createdAt,
-			&updatedAt,
-			&article.Favorited,
-			&article.FavoritesCount,
-			&rawTags,
-			&author.Following,
-			&author.Username,
-			&author.Bio,
-			&author.Image,
-		)
+		var sortKey float64
+		article, err := scanBodylessArticle(rows, &sortKey)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning article row: %w", err)
+			return nil, nil, err
 		}
+		article.sortKey = sortKey
 
-		article.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing created at date: %w", err)
-		}
-
-		article.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing updated at date: %w", err)
-		}
-
-		article.Author = &author
-
-		if rawTags == "" {
-			article.TagList = make([]string, 0)
-		} else {
-			article.TagList = strings.Split(rawTags, ",")
-		}
-
-		articles = append(articles, &article)
+		articles = append(articles, article)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over rows while fetching articles: %w", err)
+		return nil, nil, fmt.Errorf("error iterating over rows while fetching articles: %w", err)
 	}
 
-	return articles, nil
+	articles, cursors := filters.page(articles)
+	return articles, cursors, nil
 }
 
-func (repo *ArticleRepository) GetFeed(filters *PaginationFilters, userId int) ([]*BodylessArticle, error) {
+// GetFeed returns a page of the articles by authors the user follows, along with the cursors for the pages either side
+func (repo *ArticleRepository) GetFeed(filters *PaginationFilters, userId int) ([]*BodylessArticle, *PageCursors, error) {
 	articles := make([]*BodylessArticle, 0)
 
-	query := `SELECT
-				a.ArticleId,
-				a.UserId, 
-				a.Title,
-				a.Slug,
-				a.Description,
-				a.CreatedAt,
-				a.UpdatedAt,
-				(SELECT EXISTS(SELECT 1 FROM ArticleFavorite af WHERE af.ArticleId=a.ArticleId AND af.UserId=$1)) AS Favorited,
-				(SELECT COUNT(*) FROM ArticleFavorite af WHERE af.ArticleId=a.ArticleId) AS FavoritesCount,
-				COALESCE((SELECT GROUP_CONCAT(t.Tag, ',') 
-						FROM Tag t 
-						JOIN ArticleTag at ON at.TagId = t.TagId 
-						WHERE at.ArticleId = a.ArticleId), '') AS Tags,
-				EXISTS (SELECT 1 FROM Follower WHERE UserId = $1 AND FollowUserId = a.UserId) AS Following,
-				u.Username,
-				u.Bio,
-				u.Image
-			FROM Article a
-			JOIN User u ON a.UserId = u.UserId 
-			JOIN Follower f ON a.UserId = f.FollowUserId
-			WHERE f.UserId = $1
-			ORDER BY a.ArticleId DESC
-			LIMIT $2 OFFSET $3`
+	sortKey, keyset, orderBy, keysetArgs := filters.keyset()
+
+	query := `SELECT` + bodylessArticleColumns + `,
+				` + sortKey + ` AS SortKey
+` + feedArticles + `
+			AND ` + keyset + `
+			ORDER BY ` + orderBy + `
+			LIMIT @limit OFFSET @offset`
 
-	args := []any{userId, filters.Limit, filters.Offset}
+	args := []any{sql.Named("userId", userId)}
+	args = append(args, keysetArgs...)
+	// fetch one more than asked for to find out whether there's another page
+	args = append(args, sql.Named("limit", filters.Limit+1), sql.Named("offset", filters.Offset))
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
@@ -515,68 +1003,78 @@ func (repo *ArticleRepository) GetFeed(filters *PaginationFilters, userId int) (
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return articles, nil
+			return articles, &PageCursors{}, nil
 		default:
-			return nil, fmt.Errorf("error querying articles while constructing feed: %w", err)
+			return nil, nil, fmt.Errorf("error querying articles while constructing feed: %w", err)
 		}
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
-
-		var article BodylessArticle
-		var author Profile
-		var rawTags string
-		var createdAt string
-		var updatedAt string
-
-		err = rows.Scan(
-			&article.ArticleId,
-			&article.UserId,
-			&article.Title,
-			&article.Slug,
-			&article.Description,
-			&createdAt,
-			&updatedAt,
-			&article.Favorited,
-			&article.FavoritesCount,
-			&rawTags,
-			&author.Following,
-			&author.Username,
-			&author.Bio,
-			&author.Image,
-		)
+		var sortKey float64
+		article, err := scanBodylessArticle(rows, &sortKey)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning article row: %w", err)
+			return nil, nil, err
 		}
+		article.sortKey = sortKey
 
-		article.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing created at date: %w", err)
-		}
+		articles = append(articles, article)
+	}
 
-		article.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing updated at date: %w", err)
-		}
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating over rows while constructing feed: %w", err)
+	}
 
-		article.Author = &author
+	articles, cursors := filters.page(articles)
+	return articles, cursors, nil
+}
 
-		if rawTags == "" {
-			article.TagList = make([]string, 0)
-		} else {
-			article.TagList = strings.Split(rawTags, ",")
-		}
+// CountArticles counts every article matching the filters regardless of pagination. When limit is
+// above zero counting stops there, which is much cheaper when a lot of articles match.
+func (repo *ArticleRepository) CountArticles(filters *ArticleFilters, limit int) (int, error) {
+	query := `SELECT COUNT(*) FROM (SELECT a.ArticleId` + filteredArticles + `
+			LIMIT @limit)`
 
-		articles = append(articles, &article)
+	args := append(filters.args(), sql.Named("limit", countLimit(limit)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var count int
+	err := repo.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting articles: %w", err)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over rows while constructing feed: %w", err)
+	return count, nil
+}
+
+// CountFeed counts every article in the user's feed, stopping at limit if it is above zero
+func (repo *ArticleRepository) CountFeed(userId, limit int) (int, error) {
+	query := `SELECT COUNT(*) FROM (SELECT a.ArticleId` + feedArticles + `
+			LIMIT @limit)`
+
+	args := []any{sql.Named("userId", userId), sql.Named("limit", countLimit(limit))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var count int
+	err := repo.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting articles in feed: %w", err)
 	}
 
-	return articles, nil
+	return count, nil
+}
+
+// a negative LIMIT means no limit to SQLite
+func countLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
 }
 
 func (repo *ArticleRepository) DeleteArticle(articleId, userId int) (retErr error) {
@@ -636,12 +1134,12 @@ func (repo *ArticleRepository) DeleteArticle(articleId, userId int) (retErr erro
 }
 
 func (repo *ArticleRepository) FavoriteArticle(articleId, userId int) error {
-	query := `INSERT OR IGNORE INTO ArticleFavorite (ArticleId, UserId) VALUES ($1, $2)`
+	query := `INSERT OR IGNORE INTO ArticleFavorite (ArticleId, UserId, CreatedAt) VALUES ($1, $2, $3)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
 
-	_, err := repo.DB.ExecContext(ctx, query, articleId, userId)
+	_, err := repo.DB.ExecContext(ctx, query, articleId, userId, time.Now().UTC().Format(time.RFC3339Nano))
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
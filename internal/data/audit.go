@@ -0,0 +1,263 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"realworld.tayler.io/internal/validator"
+)
+
+const (
+	AuditUserLogin           = "user.login"
+	AuditUserLoginFailed     = "user.login_failed"
+	AuditUserEmailChanged    = "user.email_changed"
+	AuditUserPasswordChanged = "user.password_changed"
+	AuditUserDeleted         = "user.deleted"
+	AuditUserRestored        = "user.restored"
+	AuditUserExported        = "user.exported"
+	AuditArticleDeleted      = "article.deleted"
+	AuditArticleRestored     = "article.revision_restored"
+	AuditCommentDeleted      = "comment.deleted"
+)
+
+type AuditEvent struct {
+	AuditLogId    int       `json:"id"`
+	ActorUserId   *int      `json:"actorUserId"`
+	ActorUsername *string   `json:"actorUsername"`
+	Action        string    `json:"action"`
+	TargetType    *string   `json:"targetType"`
+	TargetId      *string   `json:"targetId"`
+	Ip            string    `json:"ip"`
+	UserAgent     string    `json:"userAgent"`
+	RequestId     string    `json:"requestId"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type AuditFilters struct {
+	Actor      *string
+	Action     *string
+	TargetType *string
+	TargetId   *string
+	Since      *time.Time
+	Until      *time.Time
+	Cursor     *int
+	Limit      int
+}
+
+func (f *AuditFilters) ParseFilters(v *validator.Validator, r *http.Request) {
+	query := r.URL.Query()
+
+	for key, dst := range map[string]**string{
+		"actor":      &f.Actor,
+		"action":     &f.Action,
+		"targetType": &f.TargetType,
+		"targetId":   &f.TargetId,
+	} {
+		if query.Has(key) {
+			value := query.Get(key)
+			*dst = &value
+			v.Check(value != "", key, "must not be blank")
+		}
+	}
+
+	for key, dst := range map[string]**time.Time{
+		"since": &f.Since,
+		"until": &f.Until,
+	} {
+		if query.Has(key) {
+			value, err := time.Parse(time.RFC3339, query.Get(key))
+			if err != nil {
+				v.AddError(key, "must be an RFC 3339 timestamp")
+			} else {
+				*dst = &value
+			}
+		}
+	}
+
+	if query.Has("cursor") {
+		auditLogId, err := decodeAuditCursor(query.Get("cursor"))
+		if err != nil {
+			v.AddError("cursor", "is invalid")
+		} else {
+			f.Cursor = &auditLogId
+		}
+	}
+
+	f.Limit = 50
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil {
+			v.AddError("limit", "must be an integer")
+		} else {
+			f.Limit = limit
+		}
+	}
+
+	v.Check(f.Limit > 0 && f.Limit <= 500, "limit", "must be between 1 and 500")
+}
+
+// the cursor is opaque to clients, but is really just the id of the last event they saw
+func encodeAuditCursor(auditLogId int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(auditLogId)))
+}
+
+func decodeAuditCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(raw))
+}
+
+type AuditRepository struct {
+	DB             *sql.DB
+	TimeoutSeconds int
+}
+
+func (repo *AuditRepository) InsertEvent(event *AuditEvent) error {
+	query := `INSERT INTO AuditLog
+				(ActorUserId, ActorUsername, Action, TargetType, TargetId, Ip, UserAgent, RequestId, CreatedAt)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				RETURNING AuditLogId`
+
+	args := []any{
+		event.ActorUserId,
+		event.ActorUsername,
+		event.Action,
+		event.TargetType,
+		event.TargetId,
+		event.Ip,
+		event.UserAgent,
+		event.RequestId,
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	err := repo.DB.QueryRowContext(ctx, query, args...).Scan(&event.AuditLogId)
+	if err != nil {
+		return fmt.Errorf("error writing audit event: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvents returns the most recent events matching the filters, along with a cursor
+// for the next page which is nil once there are no more events. The actor is looked up
+// by id since usernames can change and be taken again once an account is purged
+func (repo *AuditRepository) GetEvents(filters *AuditFilters) ([]*AuditEvent, *string, error) {
+	events := make([]*AuditEvent, 0)
+
+	query := `SELECT
+				AuditLogId,
+				ActorUserId,
+				ActorUsername,
+				Action,
+				TargetType,
+				TargetId,
+				Ip,
+				UserAgent,
+				RequestId,
+				CreatedAt
+			FROM AuditLog
+			WHERE ($1 IS NULL OR ActorUserId = (SELECT UserId FROM User WHERE Username = $1))
+			AND ($2 IS NULL OR Action = $2)
+			AND ($3 IS NULL OR TargetType = $3)
+			AND ($4 IS NULL OR TargetId = $4)
+			AND ($5 IS NULL OR julianday(CreatedAt) >= julianday($5))
+			AND ($6 IS NULL OR julianday(CreatedAt) < julianday($6))
+			AND ($7 IS NULL OR AuditLogId < $7)
+			ORDER BY AuditLogId DESC
+			LIMIT $8`
+
+	args := []any{
+		filters.Actor,
+		filters.Action,
+		filters.TargetType,
+		filters.TargetId,
+		formatOptionalTime(filters.Since),
+		formatOptionalTime(filters.Until),
+		filters.Cursor,
+		// fetch one more than asked for to find out whether there's another page
+		filters.Limit + 1,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event AuditEvent
+		var createdAt string
+
+		err = rows.Scan(
+			&event.AuditLogId,
+			&event.ActorUserId,
+			&event.ActorUsername,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetId,
+			&event.Ip,
+			&event.UserAgent,
+			&event.RequestId,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error scanning audit event row: %w", err)
+		}
+
+		event.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing created at date: %w", err)
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating over rows while fetching audit events: %w", err)
+	}
+
+	var nextCursor *string
+	if len(events) > filters.Limit {
+		events = events[:filters.Limit]
+		cursor := encodeAuditCursor(events[len(events)-1].AuditLogId)
+		nextCursor = &cursor
+	}
+
+	return events, nextCursor, nil
+}
+
+// DeleteEventsBefore enforces the retention period, it's the only way rows ever leave the audit log
+func (repo *AuditRepository) DeleteEventsBefore(cutoff time.Time) (int64, error) {
+	query := `DELETE FROM AuditLog WHERE julianday(CreatedAt) < julianday($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	result, err := repo.DB.ExecContext(ctx, query, cutoff.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired audit events: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.UTC().Format(time.RFC3339Nano)
+	return &formatted
+}
@@ -162,6 +162,7 @@ func (repo *CommentRepository) GetCommentsForArticle(articleId, currentUserId in
   	JOIN User u ON c.UserId = u.UserId 
 	JOIN Article a ON c.ArticleId = a.ArticleId
   	WHERE a.ArticleId = $2
+	AND u.DeletedAt IS NULL
 	ORDER BY c.CommentId ASC`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
@@ -225,3 +226,84 @@ func (repo *CommentRepository) GetCommentsForArticle(articleId, currentUserId in
 
 	return comments, nil
 }
+
+// UserComment is a comment along with the slug of the article it was left on,
+// for listing a user's comments outside the context of a single article
+type UserComment struct {
+	Comment
+	ArticleSlug string `json:"articleSlug"`
+}
+
+func (repo *CommentRepository) GetCommentsByUser(userId int) ([]UserComment, error) {
+	query := `SELECT 
+	c.ArticleId,
+	c.UserId,
+	c.CommentId,
+	c.Body,
+	c.CreatedAt,
+	c.UpdatedAt,
+	a.Slug,
+	u.Username,
+	u.Bio,
+	u.Image
+  	FROM Comment c
+  	JOIN User u ON c.UserId = u.UserId 
+	JOIN Article a ON c.ArticleId = a.ArticleId
+  	WHERE c.UserId = $1
+	ORDER BY c.CommentId ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("an error occured while retrieving comments for a user: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []UserComment{}
+
+	for rows.Next() {
+
+		var comment UserComment
+		var author Profile
+		var createdAt string
+		var updatedAt string
+
+		err = rows.Scan(
+			&comment.ArticleId,
+			&comment.UserId,
+			&comment.CommentId,
+			&comment.Body,
+			&createdAt,
+			&updatedAt,
+			&comment.ArticleSlug,
+			&author.Username,
+			&author.Bio,
+			&author.Image,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("an error occured while scanning a comment: %w", err)
+		}
+
+		comment.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing created at date: %w", err)
+		}
+
+		comment.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing updated at date: %w", err)
+		}
+
+		comment.Author = &author
+
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows while fetching comments for a user: %w", err)
+	}
+
+	return comments, nil
+}
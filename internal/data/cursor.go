@@ -0,0 +1,173 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// articleOrder is one of the ways a list of articles can be sorted. Every order falls back to
+// the article id to break ties, so that the order is stable and a cursor is never ambiguous.
+// Every sort key is a number, times are julian days since text comparison goes wrong when
+// fractional seconds differ in length.
+type articleOrder struct {
+	// SQL expression for the sort key
+	key        string
+	descending bool
+	// the key is a count, which only counts what was there at @asOf, so that favorites and comments
+	// made while a client is paging don't shift the pages under it. Removing them still does.
+	counted bool
+}
+
+var articleOrders = map[string]articleOrder{
+	// drafts are only listed for their author, and sort by when they were created
+	"newest":    {key: "julianday(COALESCE(a.PublishAt, a.CreatedAt))", descending: true},
+	"oldest":    {key: "julianday(COALESCE(a.PublishAt, a.CreatedAt))", descending: false},
+	"updated":   {key: "julianday(a.UpdatedAt)", descending: true},
+	"favorited": {key: "(SELECT COUNT(*) FROM ArticleFavorite fc WHERE fc.ArticleId = a.ArticleId AND (@asOf IS NULL OR fc.CreatedAt IS NULL OR julianday(fc.CreatedAt) <= julianday(@asOf)))", descending: true, counted: true},
+	"commented": {key: "(SELECT COUNT(*) FROM Comment cc WHERE cc.ArticleId = a.ArticleId AND (@asOf IS NULL OR julianday(cc.CreatedAt) <= julianday(@asOf)))", descending: true, counted: true},
+}
+
+const defaultArticleOrder = "newest"
+
+// Cursor marks a position in a list of articles. Clients only ever see it as an opaque
+// token, so what it contains can change without breaking anyone.
+type Cursor struct {
+	// the order the list was in, a cursor can't be used with any other order
+	Order string `json:"o"`
+	// sort key and id of the article the position is next to
+	Key float64 `json:"k"`
+	Id  int     `json:"i"`
+	// whether the page is the one before the article rather than the one after it
+	Backwards bool `json:"b,omitempty"`
+	// when the first page was fetched, for orders that are counted
+	AsOf *time.Time `json:"t,omitempty"`
+}
+
+// PageCursors are the tokens for the pages either side of the page that was returned,
+// each is nil when there is nothing more in that direction
+type PageCursors struct {
+	Next *string
+	Prev *string
+}
+
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cursor
+	err = json.Unmarshal(raw, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	order, ok := articleOrders[c.Order]
+	if !ok {
+		return nil, fmt.Errorf("unknown order %q", c.Order)
+	}
+	if order.counted && (c.Key < 0 || c.Key != math.Trunc(c.Key)) {
+		return nil, fmt.Errorf("key %v isn't a count", c.Key)
+	}
+	if order.counted != (c.AsOf != nil) {
+		return nil, errors.New("time is missing or not needed")
+	}
+
+	return &c, nil
+}
+
+func (f *PaginationFilters) order() articleOrder {
+	if order, ok := articleOrders[f.Order]; ok {
+		return order
+	}
+	return articleOrders[defaultArticleOrder]
+}
+
+// keyset returns the sort key expression, the ORDER BY clause, and a condition that only matches
+// articles beyond the cursor. The condition takes the cursor's id in @cursorId and its sort key in
+// @cursorKey, and counted keys take the time they are counted at in @asOf, which are all returned
+// as args. The condition matches everything when there is no cursor. The rows nearest the cursor
+// always come first, so a page fetched backwards is in reverse.
+func (f *PaginationFilters) keyset() (key, condition, orderBy string, args []any) {
+	order := f.order()
+
+	descending := order.descending
+	var id, cursorKey any
+	if f.Cursor != nil {
+		id, cursorKey = f.Cursor.Id, f.Cursor.Key
+		if f.Cursor.Backwards {
+			descending = !descending
+		}
+	}
+	args = []any{sql.Named("cursorId", id), sql.Named("cursorKey", cursorKey), sql.Named("asOf", formatOptionalTime(f.AsOf))}
+
+	comparison, direction := ">", "ASC"
+	if descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	// a row value comparison orders by the key first, then the id
+	condition = fmt.Sprintf("(@cursorId IS NULL OR (%s, a.ArticleId) %s (@cursorKey, @cursorId))", order.key, comparison)
+	orderBy = fmt.Sprintf("%s %s, a.ArticleId %s", order.key, direction, direction)
+
+	return order.key, condition, orderBy, args
+}
+
+// page trims the extra article fetched to find out whether there is another page, puts the
+// articles back in order if the page was fetched backwards, and works out the cursors either side
+func (f *PaginationFilters) page(articles []*BodylessArticle) ([]*BodylessArticle, *PageCursors) {
+	backwards := f.Cursor != nil && f.Cursor.Backwards
+
+	hasMore := len(articles) > f.Limit
+	if hasMore {
+		articles = articles[:f.Limit]
+	}
+	if backwards {
+		slices.Reverse(articles)
+	}
+
+	cursors := &PageCursors{}
+	if len(articles) == 0 {
+		return articles, cursors
+	}
+
+	order := f.Order
+	if _, ok := articleOrders[order]; !ok {
+		order = defaultArticleOrder
+	}
+	var asOf *time.Time
+	if articleOrders[order].counted {
+		asOf = f.AsOf
+	}
+
+	first, last := articles[0], articles[len(articles)-1]
+	next := Cursor{Order: order, Key: last.sortKey, Id: last.ArticleId, AsOf: asOf}.Encode()
+	prev := Cursor{Order: order, Key: first.sortKey, Id: first.ArticleId, Backwards: true, AsOf: asOf}.Encode()
+
+	if backwards {
+		cursors.Next = &next
+		if hasMore {
+			cursors.Prev = &prev
+		}
+	} else {
+		if hasMore {
+			cursors.Next = &next
+		}
+		if f.Cursor != nil || f.Offset > 0 {
+			cursors.Prev = &prev
+		}
+	}
+
+	return articles, cursors
+}
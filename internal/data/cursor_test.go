@@ -0,0 +1,233 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	asOf := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *Cursor
+		wantErr bool
+	}{
+		{"forwards", Cursor{Order: "newest", Key: 12.0, Id: 12}.Encode(), &Cursor{Order: "newest", Key: 12.0, Id: 12}, false},
+		{"backwards", Cursor{Order: "updated", Key: 2460311.6, Id: 3, Backwards: true}.Encode(), &Cursor{Order: "updated", Key: 2460311.6, Id: 3, Backwards: true}, false},
+		{"counted", Cursor{Order: "favorited", Key: 7, Id: 3, AsOf: &asOf}.Encode(), &Cursor{Order: "favorited", Key: 7, Id: 3, AsOf: &asOf}, false},
+		{"not base64", "not a cursor!", nil, true},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("cursor")), nil, true},
+		{"unknown order", Cursor{Order: "random", Key: 1.0, Id: 1}.Encode(), nil, true},
+		{"no order", base64.RawURLEncoding.EncodeToString([]byte(`{"k":1,"i":1}`)), nil, true},
+		{"key not a number", base64.RawURLEncoding.EncodeToString([]byte(`{"o":"updated","k":"2024-01-02T03:04:05Z","i":1}`)), nil, true},
+		{"count not whole", Cursor{Order: "favorited", Key: 1.5, Id: 1, AsOf: &asOf}.Encode(), nil, true},
+		{"count negative", Cursor{Order: "commented", Key: -1, Id: 1, AsOf: &asOf}.Encode(), nil, true},
+		{"count without a time", Cursor{Order: "favorited", Key: 1, Id: 1}.Encode(), nil, true},
+		{"time not needed", Cursor{Order: "newest", Key: 1, Id: 1, AsOf: &asOf}.Encode(), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got cursor %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	// articles as they come back from the database, nearest the cursor first, with one extra
+	// to tell whether there is another page
+	fetched := func(ids ...int) []*BodylessArticle {
+		articles := make([]*BodylessArticle, 0, len(ids))
+		for _, id := range ids {
+			articles = append(articles, &BodylessArticle{ArticleId: id, sortKey: float64(id)})
+		}
+		return articles
+	}
+
+	tests := []struct {
+		name     string
+		filters  PaginationFilters
+		articles []*BodylessArticle
+		wantIds  []int
+		wantNext *Cursor
+		wantPrev *Cursor
+	}{
+		{
+			name:     "first page",
+			filters:  PaginationFilters{Limit: 2},
+			articles: fetched(5, 4, 3),
+			wantIds:  []int{5, 4},
+			wantNext: &Cursor{Order: "newest", Key: 4.0, Id: 4},
+		},
+		{
+			name:     "only page",
+			filters:  PaginationFilters{Limit: 2},
+			articles: fetched(5, 4),
+			wantIds:  []int{5, 4},
+		},
+		{
+			name:     "after an offset",
+			filters:  PaginationFilters{Limit: 2, Offset: 2},
+			articles: fetched(3, 2),
+			wantIds:  []int{3, 2},
+			wantPrev: &Cursor{Order: "newest", Key: 3.0, Id: 3, Backwards: true},
+		},
+		{
+			name:     "middle page",
+			filters:  PaginationFilters{Limit: 2, Order: "oldest", Cursor: &Cursor{Order: "oldest", Key: 2.0, Id: 2}},
+			articles: fetched(3, 4, 5),
+			wantIds:  []int{3, 4},
+			wantNext: &Cursor{Order: "oldest", Key: 4.0, Id: 4},
+			wantPrev: &Cursor{Order: "oldest", Key: 3.0, Id: 3, Backwards: true},
+		},
+		{
+			name:     "backwards",
+			filters:  PaginationFilters{Limit: 2, Cursor: &Cursor{Order: "newest", Key: 3.0, Id: 3, Backwards: true}},
+			articles: fetched(4, 5, 6),
+			wantIds:  []int{5, 4},
+			wantNext: &Cursor{Order: "newest", Key: 4.0, Id: 4},
+			wantPrev: &Cursor{Order: "newest", Key: 5.0, Id: 5, Backwards: true},
+		},
+		{
+			name:     "backwards to the start",
+			filters:  PaginationFilters{Limit: 2, Cursor: &Cursor{Order: "newest", Key: 4.0, Id: 4, Backwards: true}},
+			articles: fetched(5, 6),
+			wantIds:  []int{6, 5},
+			wantNext: &Cursor{Order: "newest", Key: 5.0, Id: 5},
+		},
+		{
+			name:     "empty",
+			filters:  PaginationFilters{Limit: 2, Cursor: &Cursor{Order: "newest", Key: 1.0, Id: 1}},
+			articles: fetched(),
+			wantIds:  []int{},
+		},
+	}
+
+	decode := func(token *string) *Cursor {
+		if token == nil {
+			return nil
+		}
+		c, err := DecodeCursor(*token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			articles, cursors := tt.filters.page(tt.articles)
+
+			ids := make([]int, 0, len(articles))
+			for _, article := range articles {
+				ids = append(ids, article.ArticleId)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("got articles %v, want %v", ids, tt.wantIds)
+			}
+
+			if next := decode(cursors.Next); !reflect.DeepEqual(next, tt.wantNext) {
+				t.Errorf("got next cursor %+v, want %+v", next, tt.wantNext)
+			}
+			if prev := decode(cursors.Prev); !reflect.DeepEqual(prev, tt.wantPrev) {
+				t.Errorf("got previous cursor %+v, want %+v", prev, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		name          string
+		filters       PaginationFilters
+		wantKey       string
+		wantCondition string
+		wantOrderBy   string
+	}{
+		{
+			name:          "default order",
+			filters:       PaginationFilters{},
+			wantKey:       "julianday(COALESCE(a.PublishAt, a.CreatedAt))",
+			wantCondition: "(@cursorId IS NULL OR (julianday(COALESCE(a.PublishAt, a.CreatedAt)), a.ArticleId) < (@cursorKey, @cursorId))",
+			wantOrderBy:   "julianday(COALESCE(a.PublishAt, a.CreatedAt)) DESC, a.ArticleId DESC",
+		},
+		{
+			name:          "unknown order",
+			filters:       PaginationFilters{Order: "random"},
+			wantKey:       "julianday(COALESCE(a.PublishAt, a.CreatedAt))",
+			wantCondition: "(@cursorId IS NULL OR (julianday(COALESCE(a.PublishAt, a.CreatedAt)), a.ArticleId) < (@cursorKey, @cursorId))",
+			wantOrderBy:   "julianday(COALESCE(a.PublishAt, a.CreatedAt)) DESC, a.ArticleId DESC",
+		},
+		{
+			name:          "oldest",
+			filters:       PaginationFilters{Order: "oldest"},
+			wantKey:       "julianday(COALESCE(a.PublishAt, a.CreatedAt))",
+			wantCondition: "(@cursorId IS NULL OR (julianday(COALESCE(a.PublishAt, a.CreatedAt)), a.ArticleId) > (@cursorKey, @cursorId))",
+			wantOrderBy:   "julianday(COALESCE(a.PublishAt, a.CreatedAt)) ASC, a.ArticleId ASC",
+		},
+		{
+			name:          "updated",
+			filters:       PaginationFilters{Order: "updated"},
+			wantKey:       "julianday(a.UpdatedAt)",
+			wantCondition: "(@cursorId IS NULL OR (julianday(a.UpdatedAt), a.ArticleId) < (@cursorKey, @cursorId))",
+			wantOrderBy:   "julianday(a.UpdatedAt) DESC, a.ArticleId DESC",
+		},
+		{
+			name:          "backwards flips the direction",
+			filters:       PaginationFilters{Order: "updated", Cursor: &Cursor{Order: "updated", Key: 2460311.6, Id: 3, Backwards: true}},
+			wantKey:       "julianday(a.UpdatedAt)",
+			wantCondition: "(@cursorId IS NULL OR (julianday(a.UpdatedAt), a.ArticleId) > (@cursorKey, @cursorId))",
+			wantOrderBy:   "julianday(a.UpdatedAt) ASC, a.ArticleId ASC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, condition, orderBy, _ := tt.filters.keyset()
+			if key != tt.wantKey {
+				t.Errorf("got key %q, want %q", key, tt.wantKey)
+			}
+			if condition != tt.wantCondition {
+				t.Errorf("got condition %q, want %q", condition, tt.wantCondition)
+			}
+			if orderBy != tt.wantOrderBy {
+				t.Errorf("got order by %q, want %q", orderBy, tt.wantOrderBy)
+			}
+		})
+	}
+}
+
+func TestKeysetArgs(t *testing.T) {
+	asOf := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cursor   *Cursor
+		asOf     *time.Time
+		wantArgs []any
+	}{
+		{"no cursor", nil, nil, []any{sql.Named("cursorId", nil), sql.Named("cursorKey", nil), sql.Named("asOf", (*string)(nil))}},
+		{"cursor", &Cursor{Order: "favorited", Key: 7.0, Id: 3, AsOf: &asOf}, &asOf, []any{sql.Named("cursorId", 3), sql.Named("cursorKey", 7.0), sql.Named("asOf", ptr("2024-01-02T03:04:05Z"))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := PaginationFilters{Order: "favorited", Cursor: tt.cursor, AsOf: tt.asOf}
+			_, _, _, args := filters.keyset()
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got args %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
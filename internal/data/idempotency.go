@@ -0,0 +1,125 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+)
+
+// IdempotencyRecord is the response that was sent the first time a user made a request with a
+// given Idempotency-Key, so that it can be replayed if the request is retried
+type IdempotencyRecord struct {
+	UserId          int
+	Key             string
+	RequestHash     string
+	ResponseStatus  int
+	ResponseHeaders http.Header
+	ResponseBody    []byte
+	CreatedAt       time.Time
+}
+
+type IdempotencyRepository struct {
+	DB             *sql.DB
+	TimeoutSeconds int
+}
+
+// GetRecord returns the record for the key, ignoring any that were created before notBefore
+func (repo *IdempotencyRepository) GetRecord(userId int, key string, notBefore time.Time) (*IdempotencyRecord, error) {
+	query := `SELECT RequestHash, ResponseStatus, ResponseHeaders, ResponseBody, CreatedAt
+			  FROM IdempotencyKey
+			  WHERE UserId = $1 AND Key = $2 AND CreatedAt >= $3`
+
+	args := []any{userId, key, notBefore.UTC().Format(time.RFC3339Nano)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	record := &IdempotencyRecord{
+		UserId: userId,
+		Key:    key,
+	}
+	var rawHeaders string
+	var createdAt string
+
+	err := repo.DB.QueryRowContext(ctx, query, args...).Scan(
+		&record.RequestHash,
+		&record.ResponseStatus,
+		&rawHeaders,
+		&record.ResponseBody,
+		&createdAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrIdempotencyKeyNotFound
+		default:
+			return nil, fmt.Errorf("error looking up idempotency key: %w", err)
+		}
+	}
+
+	err = json.Unmarshal([]byte(rawHeaders), &record.ResponseHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding stored response headers: %w", err)
+	}
+
+	record.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created at date: %w", err)
+	}
+
+	return record, nil
+}
+
+// SaveRecord stores the response for a key, replacing any expired record that hasn't been cleaned up yet
+func (repo *IdempotencyRepository) SaveRecord(record *IdempotencyRecord) error {
+	query := `INSERT OR REPLACE INTO IdempotencyKey
+				(UserId, Key, RequestHash, ResponseStatus, ResponseHeaders, ResponseBody, CreatedAt)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	rawHeaders, err := json.Marshal(record.ResponseHeaders)
+	if err != nil {
+		return fmt.Errorf("error encoding response headers: %w", err)
+	}
+
+	args := []any{
+		record.UserId,
+		record.Key,
+		record.RequestHash,
+		record.ResponseStatus,
+		string(rawHeaders),
+		record.ResponseBody,
+		record.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	_, err = repo.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error saving idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *IdempotencyRepository) DeleteRecordsBefore(cutoff time.Time) (int64, error) {
+	query := `DELETE FROM IdempotencyKey WHERE CreatedAt < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	result, err := repo.DB.ExecContext(ctx, query, cutoff.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired idempotency keys: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -17,8 +17,9 @@ type JwtTokenService struct {
 }
 
 type CustomClaims struct {
-	UserId   int    `json:"user_id"`
-	Username string `json:"username"`
+	UserId       int    `json:"user_id"`
+	Username     string `json:"username"`
+	TokenVersion int    `json:"token_version"`
 	jwt.RegisteredClaims
 }
 
@@ -26,9 +27,10 @@ func (t JwtTokenService) CreateToken(user *User) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
 		jwt.MapClaims{
-			"user_id":  user.UserId,
-			"username": user.Username,
-			"exp":      time.Now().Add(time.Hour * 24).Unix(),
+			"user_id":       user.UserId,
+			"username":      user.Username,
+			"token_version": user.TokenVersion,
+			"exp":           time.Now().Add(time.Hour * 24).Unix(),
 		})
 
 	tokenString, err := token.SignedString(t.SecretKey)
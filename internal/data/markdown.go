@@ -0,0 +1,143 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// RenderedBody is an article's Markdown body as sanitized HTML, with the headings in it
+type RenderedBody struct {
+	HTML            string
+	TableOfContents []Heading
+}
+
+// Heading is an entry in an article's table of contents, linking to the heading's anchor
+type Heading struct {
+	Level int    `json:"level"`
+	Id    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+// headingIdPrefix starts every heading anchor, as GitHub does, so that an id can't clash with
+// anything else on the page the HTML is put into. Without it a heading such as "# Config" would
+// become a global named config in the browser, which scripts on the page may rely on.
+const headingIdPrefix = "user-content-"
+
+// CommonMark, plus the GFM tables, strikethrough, task lists and autolinks. Raw HTML in the
+// Markdown is left out by goldmark, and anything that gets through is stripped by the policy.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// the anchors from headingIds, which may be in scripts the UGC policy's id pattern doesn't allow
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^`+headingIdPrefix+`[\p{L}\p{M}\p{N}-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	// for syntax highlighting on the client, e.g. language-go
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	// task list check boxes, which can't be ticked on the page
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(checked|disabled|)$`)).OnElements("input")
+	return p
+}()
+
+// RenderMarkdown renders an article's body to HTML that is safe to put straight into a page
+func RenderMarkdown(body string) (*RenderedBody, error) {
+	source := []byte(body)
+
+	ctx := parser.NewContext(parser.WithIDs(&headingIds{used: make(map[string]bool)}))
+	document := markdown.Parser().Parse(text.NewReader(source), parser.WithContext(ctx))
+
+	var buf bytes.Buffer
+	err := markdown.Renderer().Render(&buf, source, document)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering markdown: %w", err)
+	}
+
+	rendered := &RenderedBody{
+		HTML:            markdownPolicy.Sanitize(buf.String()),
+		TableOfContents: make([]Heading, 0),
+	}
+
+	err = ast.Walk(document, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		id, _ := heading.AttributeString("id")
+		idBytes, _ := id.([]byte)
+
+		rendered.TableOfContents = append(rendered.TableOfContents, Heading{
+			Level: heading.Level,
+			Id:    string(idBytes),
+			Text:  strings.TrimSpace(plainText(heading, source)),
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error building table of contents: %w", err)
+	}
+
+	return rendered, nil
+}
+
+// plainText is the text of n's inline children without any of the Markdown around it
+func plainText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch child := child.(type) {
+		case *ast.Text:
+			b.Write(child.Segment.Value(source))
+			if child.SoftLineBreak() || child.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(child.Value)
+		case *ast.AutoLink:
+			b.Write(child.Label(source))
+		case *ast.RawHTML:
+			// left out of the HTML too
+		default:
+			b.WriteString(plainText(child, source))
+		}
+	}
+	return b.String()
+}
+
+// headingIds gives headings anchors the same way titles are turned into slugs, so that headings
+// in other scripts get readable anchors, adding a number to any that come up more than once.
+// Every anchor starts with headingIdPrefix.
+type headingIds struct {
+	used map[string]bool
+}
+
+func (ids *headingIds) Generate(value []byte, kind ast.NodeKind) []byte {
+	base := dashed(string(value))
+	if base == "" {
+		base = "section"
+	}
+
+	id := base
+	for i := 1; ids.used[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	ids.used[id] = true
+
+	return []byte(headingIdPrefix + id)
+}
+
+func (ids *headingIds) Put(value []byte) {
+	ids.used[strings.TrimPrefix(string(value), headingIdPrefix)] = true
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownHeadings(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Heading
+	}{
+		{"none", "Just a paragraph.", []Heading{}},
+		{"prefixed", "# Config", []Heading{{1, "user-content-config", "Config"}}},
+		{"repeated", "## Setup\n\n## Setup\n\n## Setup", []Heading{
+			{2, "user-content-setup", "Setup"},
+			{2, "user-content-setup-1", "Setup"},
+			{2, "user-content-setup-2", "Setup"},
+		}},
+		{"transliterated", "# Привет мир", []Heading{{1, "user-content-privet-mir", "Привет мир"}}},
+		{"vowel signs", "# नमस्ते", []Heading{{1, "user-content-नमस्ते", "नमस्ते"}}},
+		{"only punctuation", "# ???", []Heading{{1, "user-content-section", "???"}}},
+		{"inline markup", "# Using `go vet` *well*", []Heading{{1, "user-content-using-go-vet-well", "Using go vet well"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := RenderMarkdown(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(rendered.TableOfContents, tt.want) {
+				t.Errorf("got table of contents %+v, want %+v", rendered.TableOfContents, tt.want)
+			}
+
+			// the ids in the HTML have to be the ones the table of contents links to
+			for _, heading := range tt.want {
+				if !strings.Contains(rendered.HTML, `id="`+heading.Id+`"`) {
+					t.Errorf("HTML %q has no heading with id %q", rendered.HTML, heading.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		banned string
+	}{
+		{"script", "<script>alert(1)</script>", "<script"},
+		{"javascript link", "[click](javascript:alert(1))", "javascript:"},
+		{"event handler", `<img src="x" onerror="alert(1)">`, "onerror"},
+		{"unprefixed id", `<h1 id="config">Config</h1>`, `id="config"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := RenderMarkdown(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(rendered.HTML, tt.banned) {
+				t.Errorf("got HTML %q containing %q", rendered.HTML, tt.banned)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"realworld.tayler.io/internal/validator"
+)
+
+// validatePublishing checks that publishAt is only given for scheduled articles, and is in the future
+func validatePublishing(v *validator.Validator, status *string, publishAt *time.Time) {
+	if status != nil {
+		v.Check(slices.Contains(articleStatuses, *status), "status", "must be one of draft, scheduled or published")
+		if *status == ArticleStatusScheduled {
+			v.Check(publishAt != nil, "publishAt", "must be provided when status is scheduled")
+		} else {
+			v.Check(publishAt == nil, "publishAt", "must only be provided when status is scheduled")
+		}
+	}
+	if publishAt != nil {
+		v.Check(publishAt.After(time.Now()), "publishAt", "must be in the future")
+	}
+}
+
+// publishing works out the status and publish time an article is saved with. Leaving the
+// status out means the article is published straight away, unless it is given a time to be
+// published at. Published articles keep the time they were first published at, if they have one.
+func publishing(status *string, publishAt *time.Time, now time.Time) (string, *time.Time) {
+	switch {
+	case status == nil && publishAt != nil:
+		return ArticleStatusScheduled, publishAt
+	case status == nil || *status == ArticleStatusPublished:
+		if publishAt == nil {
+			publishAt = &now
+		}
+		return ArticleStatusPublished, publishAt
+	case *status == ArticleStatusDraft:
+		return ArticleStatusDraft, nil
+	default:
+		return *status, publishAt
+	}
+}
+
+// UserArticleFilters are for an author listing their own articles, whatever their status
+type UserArticleFilters struct {
+	// only articles with this status when set
+	Status *string
+	PaginationFilters
+}
+
+func (f *UserArticleFilters) ParseFilters(v *validator.Validator, r *http.Request) {
+	if r.URL.Query().Has("status") {
+		status := r.URL.Query().Get("status")
+		v.Check(slices.Contains(articleStatuses, status), "status", "must be one of draft, scheduled or published")
+		f.Status = &status
+	}
+
+	f.PaginationFilters.ParseFilters(v, r)
+}
+
+// userArticles selects the articles written by the user in @userId, with the status in @status,
+// shared by GetUserArticles and CountUserArticles
+const userArticles = `
+			FROM Article a
+			JOIN User u ON a.UserId = u.UserId
+			WHERE a.UserId = @userId
+			AND (@status IS NULL OR a.Status = @status)`
+
+// GetUserArticles returns a page of the user's own articles, including the ones that haven't been published
+func (repo *ArticleRepository) GetUserArticles(filters *UserArticleFilters, userId int) ([]*BodylessArticle, *PageCursors, error) {
+	articles := make([]*BodylessArticle, 0)
+
+	sortKey, keyset, orderBy, keysetArgs := filters.keyset()
+
+	query := `SELECT` + bodylessArticleColumns + `,
+				` + sortKey + ` AS SortKey
+` + userArticles + `
+			AND ` + keyset + `
+			ORDER BY ` + orderBy + `
+			LIMIT @limit OFFSET @offset`
+
+	args := []any{sql.Named("userId", userId), sql.Named("status", filters.Status)}
+	args = append(args, keysetArgs...)
+	// fetch one more than asked for to find out whether there's another page
+	args = append(args, sql.Named("limit", filters.Limit+1), sql.Named("offset", filters.Offset))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return articles, &PageCursors{}, nil
+		default:
+			return nil, nil, fmt.Errorf("error querying articles while listing a user's articles: %w", err)
+		}
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var sortKey float64
+		article, err := scanBodylessArticle(rows, &sortKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		article.sortKey = sortKey
+
+		articles = append(articles, article)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating over rows while listing a user's articles: %w", err)
+	}
+
+	articles, cursors := filters.page(articles)
+	return articles, cursors, nil
+}
+
+// CountUserArticles counts the user's own articles with the status in the filters, stopping at limit if it is above zero
+func (repo *ArticleRepository) CountUserArticles(filters *UserArticleFilters, userId, limit int) (int, error) {
+	query := `SELECT COUNT(*) FROM (SELECT a.ArticleId` + userArticles + `
+			LIMIT @limit)`
+
+	args := []any{sql.Named("userId", userId), sql.Named("status", filters.Status), sql.Named("limit", countLimit(limit))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var count int
+	err := repo.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting a user's articles: %w", err)
+	}
+
+	return count, nil
+}
+
+// PublishScheduledArticles publishes every scheduled article that is due by now. The times are
+// compared as julian days since text comparison goes wrong when fractional seconds differ in length.
+func (repo *ArticleRepository) PublishScheduledArticles(now time.Time) (int64, error) {
+	query := `UPDATE Article
+			  SET Status = 'published',
+			      Version = Version + 1
+			  WHERE Status = 'scheduled' AND julianday(PublishAt) <= julianday($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	result, err := repo.DB.ExecContext(ctx, query, now.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, fmt.Errorf("error publishing scheduled articles: %w", err)
+	}
+
+	published, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error publishing scheduled articles: %w", err)
+	}
+
+	return published, nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"realworld.tayler.io/internal/validator"
+)
+
+func TestPublishing(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(24 * time.Hour)
+	earlier := now.Add(-24 * time.Hour)
+
+	tests := []struct {
+		name          string
+		status        *string
+		publishAt     *time.Time
+		wantStatus    string
+		wantPublishAt *time.Time
+	}{
+		{"default", nil, nil, ArticleStatusPublished, &now},
+		{"default with a time", nil, &later, ArticleStatusScheduled, &later},
+		{"published", ptr(ArticleStatusPublished), nil, ArticleStatusPublished, &now},
+		{"already published", ptr(ArticleStatusPublished), &earlier, ArticleStatusPublished, &earlier},
+		{"draft", ptr(ArticleStatusDraft), nil, ArticleStatusDraft, nil},
+		{"draft drops the time", ptr(ArticleStatusDraft), &later, ArticleStatusDraft, nil},
+		{"scheduled", ptr(ArticleStatusScheduled), &later, ArticleStatusScheduled, &later},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, publishAt := publishing(tt.status, tt.publishAt, now)
+			if status != tt.wantStatus {
+				t.Errorf("got status %q, want %q", status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(publishAt, tt.wantPublishAt) {
+				t.Errorf("got publish time %v, want %v", publishAt, tt.wantPublishAt)
+			}
+		})
+	}
+}
+
+func TestValidatePublishing(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		status     *string
+		publishAt  *time.Time
+		wantErrors map[string]string
+	}{
+		{"nothing", nil, nil, map[string]string{}},
+		{"draft", ptr(ArticleStatusDraft), nil, map[string]string{}},
+		{"scheduled", ptr(ArticleStatusScheduled), &later, map[string]string{}},
+		{"only a time", nil, &later, map[string]string{}},
+		{"unknown status", ptr("archived"), nil, map[string]string{"status": "must be one of draft, scheduled or published"}},
+		{"scheduled without a time", ptr(ArticleStatusScheduled), nil, map[string]string{"publishAt": "must be provided when status is scheduled"}},
+		{"draft with a time", ptr(ArticleStatusDraft), &later, map[string]string{"publishAt": "must only be provided when status is scheduled"}},
+		{"in the past", ptr(ArticleStatusScheduled), &earlier, map[string]string{"publishAt": "must be in the future"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			validatePublishing(v, tt.status, tt.publishAt)
+			if !reflect.DeepEqual(v.Errors, tt.wantErrors) {
+				t.Errorf("got errors %v, want %v", v.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
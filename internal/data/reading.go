@@ -0,0 +1,174 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	// a typical adult reading speed for prose on a screen
+	wordsPerMinute = 230
+	// descriptions shorter than this don't say enough about an article, so an excerpt is made
+	minDescriptionLength = 50
+	// excerpts are cut at the last sentence that fits, or the last word with an ellipsis
+	maxExcerptLength = 280
+	// how many articles the backfill works out the stats for in one go
+	readingStatsBatchSize = 100
+)
+
+// readingStats works out an article's word count, reading time and excerpt from its Markdown
+// body. The excerpt is nil when the description is long enough to be shown instead.
+func readingStats(description, body string) (int, int, *string) {
+	source := []byte(body)
+	document := markdown.Parser().Parse(text.NewReader(source))
+
+	var words int
+	var prose []string
+
+	ast.Walk(document, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Paragraph, *ast.TextBlock:
+			// text blocks are the paragraphs in a tight list
+			text := plainText(n, source)
+			words += len(strings.Fields(text))
+			prose = append(prose, strings.Join(strings.Fields(text), " "))
+			return ast.WalkSkipChildren, nil
+		case *ast.Heading:
+			words += len(strings.Fields(plainText(n, source)))
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				words += len(strings.Fields(string(segment.Value(source))))
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+
+		// table cells and anything else with inline content of its own
+		if n.Type() == ast.TypeBlock && n.HasChildren() && n.FirstChild().Type() == ast.TypeInline {
+			words += len(strings.Fields(plainText(n, source)))
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	readingTime := (words + wordsPerMinute - 1) / wordsPerMinute
+
+	if utf8.RuneCountInString(strings.TrimSpace(description)) >= minDescriptionLength {
+		return words, readingTime, nil
+	}
+
+	excerpt := truncateExcerpt(strings.Join(prose, " "), maxExcerptLength)
+	if excerpt == "" {
+		return words, readingTime, nil
+	}
+	return words, readingTime, &excerpt
+}
+
+// truncateExcerpt cuts s down to at most max runes at the end of a sentence, or at the end of
+// a word followed by an ellipsis if the first sentence is already too long
+func truncateExcerpt(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+
+	// the last sentence end that fits, a full stop or the like followed by a space or the end
+	for i := max - 1; i > 0; i-- {
+		if strings.ContainsRune(".!?…", runes[i]) && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			return string(runes[:i+1])
+		}
+	}
+
+	// leave room for the ellipsis
+	cut := runes[:max-1]
+	for i := len(cut) - 1; i > 0; i-- {
+		if unicode.IsSpace(cut[i]) {
+			cut = cut[:i]
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+// BackfillReadingStats works out the reading stats of articles saved before they were stored,
+// a batch at a time, returning how many articles were filled in
+func (repo *ArticleRepository) BackfillReadingStats() (int, error) {
+	selectQuery := `SELECT ArticleId, Description, Body
+					FROM Article
+					WHERE WordCount IS NULL
+					LIMIT $1`
+
+	// the stats are derived from the body, so the article's version and UpdatedAt are left alone
+	updateQuery := `UPDATE Article
+					SET WordCount = $1, ReadingTimeMinutes = $2, Excerpt = $3
+					WHERE ArticleId = $4`
+
+	type pending struct {
+		articleId   int
+		description string
+		body        string
+	}
+
+	filled := 0
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+
+		rows, err := repo.DB.QueryContext(ctx, selectQuery, readingStatsBatchSize)
+		if err != nil {
+			cancel()
+			return filled, fmt.Errorf("error querying articles without reading stats: %w", err)
+		}
+
+		batch := make([]pending, 0, readingStatsBatchSize)
+		for rows.Next() {
+			var p pending
+			err = rows.Scan(&p.articleId, &p.description, &p.body)
+			if err != nil {
+				rows.Close()
+				cancel()
+				return filled, fmt.Errorf("error scanning article row: %w", err)
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			cancel()
+			return filled, fmt.Errorf("error iterating over rows while backfilling reading stats: %w", err)
+		}
+
+		for _, p := range batch {
+			words, readingTime, excerpt := readingStats(p.description, p.body)
+
+			_, err = repo.DB.ExecContext(ctx, updateQuery, words, readingTime, excerpt, p.articleId)
+			if err != nil {
+				cancel()
+				return filled, fmt.Errorf("error saving reading stats: %w", err)
+			}
+			filled++
+		}
+		cancel()
+
+		if len(batch) < readingStatsBatchSize {
+			return filled, nil
+		}
+	}
+}
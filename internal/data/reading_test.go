@@ -0,0 +1,116 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadingStats(t *testing.T) {
+	longDescription := strings.Repeat("long enough ", 5)
+
+	tests := []struct {
+		name            string
+		description     string
+		body            string
+		wantWords       int
+		wantReadingTime int
+		wantExcerpt     *string
+	}{
+		{
+			name:        "empty",
+			description: longDescription,
+		},
+		{
+			name:            "paragraphs",
+			description:     longDescription,
+			body:            "One two three.\n\nFour *five* six.",
+			wantWords:       6,
+			wantReadingTime: 1,
+		},
+		{
+			name:            "headings lists and code",
+			description:     longDescription,
+			body:            "# A heading\n\n- one\n- two\n\n```go\nfmt.Println(\"hi\")\n```",
+			wantWords:       5,
+			wantReadingTime: 1,
+		},
+		{
+			name:            "raw html",
+			description:     longDescription,
+			body:            "<div>\nnot counted\n</div>\n\nCounted.",
+			wantWords:       1,
+			wantReadingTime: 1,
+		},
+		{
+			name:            "rounds the reading time up",
+			description:     longDescription,
+			body:            strings.Repeat("word ", wordsPerMinute+1),
+			wantWords:       wordsPerMinute + 1,
+			wantReadingTime: 2,
+		},
+		{
+			name:            "short description",
+			description:     "Short",
+			body:            "# Title\n\nFirst   paragraph\nwrapped.\n\n```\ncode\n```\n\nSecond *one*.",
+			wantWords:       7,
+			wantReadingTime: 1,
+			wantExcerpt:     ptr("First paragraph wrapped. Second one."),
+		},
+		{
+			name:            "short description and no prose",
+			description:     "Short",
+			body:            "```\ncode only\n```",
+			wantWords:       2,
+			wantReadingTime: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, readingTime, excerpt := readingStats(tt.description, tt.body)
+			if words != tt.wantWords {
+				t.Errorf("got %d words, want %d", words, tt.wantWords)
+			}
+			if readingTime != tt.wantReadingTime {
+				t.Errorf("got a reading time of %d, want %d", readingTime, tt.wantReadingTime)
+			}
+			switch {
+			case excerpt == nil && tt.wantExcerpt != nil:
+				t.Errorf("got no excerpt, want %q", *tt.wantExcerpt)
+			case excerpt != nil && tt.wantExcerpt == nil:
+				t.Errorf("got excerpt %q, want none", *excerpt)
+			case excerpt != nil && *excerpt != *tt.wantExcerpt:
+				t.Errorf("got excerpt %q, want %q", *excerpt, *tt.wantExcerpt)
+			}
+		})
+	}
+}
+
+func TestTruncateExcerpt(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{"fits", "Short enough.", 20, "Short enough."},
+		{"at a sentence", "First sentence. Second sentence.", 20, "First sentence."},
+		{"question", "Why not? Because.", 12, "Why not?"},
+		{"not at a decimal point", "Version 1.22 is out", 12, "Version…"},
+		{"at a word", "One long first sentence without an end", 20, "One long first…"},
+		{"trailing punctuation", "Hello, world and more", 8, "Hello…"},
+		{"multibyte", "Привет мир и все", 12, "Привет мир…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateExcerpt(tt.s, tt.max); got != tt.want {
+				t.Errorf("truncateExcerpt(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
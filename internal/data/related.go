@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// how much sharing tags counts towards articles being related compared to being favorited by the
+// same users, both are a Jaccard index between 0 and 1
+const (
+	relatedTagWeight      = 0.6
+	relatedFavoriteWeight = 0.4
+)
+
+// RelatedArticleIds ranks the published articles that share tags or favoriters with the article
+// in $1, most related first. The Jaccard index of two sets is the size of their intersection over
+// the size of their union, which doesn't favour articles just for having lots of tags or favorites.
+// Either index is zero when neither article has any tags or favorites.
+func (repo *ArticleRepository) RelatedArticleIds(articleId int, excludeSameAuthor bool, limit int) ([]int, error) {
+	query := `WITH
+				SourceTags AS (SELECT TagId FROM ArticleTag WHERE ArticleId = $1),
+				SourceFavorites AS (SELECT UserId FROM ArticleFavorite WHERE ArticleId = $1),
+				SharedTags AS (
+					SELECT ArticleId, COUNT(*) AS Shared FROM ArticleTag
+					WHERE TagId IN (SELECT TagId FROM SourceTags) AND ArticleId <> $1
+					GROUP BY ArticleId
+				),
+				SharedFavorites AS (
+					SELECT ArticleId, COUNT(*) AS Shared FROM ArticleFavorite
+					WHERE UserId IN (SELECT UserId FROM SourceFavorites) AND ArticleId <> $1
+					GROUP BY ArticleId
+				),
+				Candidates AS (
+					SELECT ArticleId FROM SharedTags
+					UNION
+					SELECT ArticleId FROM SharedFavorites
+				)
+			  SELECT c.ArticleId
+			  FROM Candidates c
+			  JOIN Article a ON a.ArticleId = c.ArticleId
+			  JOIN User u ON a.UserId = u.UserId
+			  LEFT JOIN SharedTags st ON st.ArticleId = c.ArticleId
+			  LEFT JOIN SharedFavorites sf ON sf.ArticleId = c.ArticleId
+			  WHERE a.Status = 'published'
+			  AND u.DeletedAt IS NULL
+			  AND ($2 = 0 OR a.UserId <> (SELECT UserId FROM Article WHERE ArticleId = $1))
+			  ORDER BY
+				$3 * COALESCE(COALESCE(st.Shared, 0) * 1.0 / NULLIF((SELECT COUNT(*) FROM SourceTags)
+					+ (SELECT COUNT(*) FROM ArticleTag WHERE ArticleId = c.ArticleId) - COALESCE(st.Shared, 0), 0), 0)
+				+ $4 * COALESCE(COALESCE(sf.Shared, 0) * 1.0 / NULLIF((SELECT COUNT(*) FROM SourceFavorites)
+					+ (SELECT COUNT(*) FROM ArticleFavorite WHERE ArticleId = c.ArticleId) - COALESCE(sf.Shared, 0), 0), 0) DESC,
+				a.ArticleId DESC
+			  LIMIT $5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, articleId, excludeSameAuthor, relatedTagWeight, relatedFavoriteWeight, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying related articles: %w", err)
+	}
+
+	defer rows.Close()
+
+	ids := make([]int, 0, limit)
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning related article row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows while ranking related articles: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetArticlesByIds returns the published articles with the ids, in the same order. Articles that
+// have since been deleted or unpublished are left out.
+func (repo *ArticleRepository) GetArticlesByIds(ids []int, userId int) ([]*BodylessArticle, error) {
+	articles := make([]*BodylessArticle, 0, len(ids))
+	if len(ids) == 0 {
+		return articles, nil
+	}
+
+	query := `SELECT` + bodylessArticleColumns + `
+			FROM json_each(@ids) ids
+			JOIN Article a ON a.ArticleId = ids.value
+			JOIN User u ON a.UserId = u.UserId
+			WHERE a.Status = 'published'
+			AND u.DeletedAt IS NULL
+			ORDER BY ids.key`
+
+	raw, _ := json.Marshal(ids)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, sql.Named("userId", userId), sql.Named("ids", string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("error querying articles by id: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		article, err := scanBodylessArticle(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		articles = append(articles, article)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows while fetching articles by id: %w", err)
+	}
+
+	return articles, nil
+}
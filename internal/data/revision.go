@@ -0,0 +1,280 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// BodylessRevision is a revision as it appears in a list
+type BodylessRevision struct {
+	// counts up from 1 for each article
+	Number      int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// the revision this one was restored from, if it was
+	RestoredFrom *int `json:"restoredFrom"`
+}
+
+// ArticleRevision is an article's title, description and body as they were after a change. Revisions
+// are never changed once written, restoring an old one adds a new revision with the same content.
+type ArticleRevision struct {
+	BodylessRevision
+	Body string `json:"body"`
+}
+
+// RevisionDiff is the line by line difference between two revisions, From is nil when comparing
+// the first revision with an empty article
+type RevisionDiff struct {
+	From        *int       `json:"from"`
+	To          int        `json:"to"`
+	Title       []DiffLine `json:"title"`
+	Description []DiffLine `json:"description"`
+	Body        []DiffLine `json:"body"`
+}
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffLine struct {
+	// one of DiffEqual, DiffInsert or DiffDelete
+	Op   string `json:"op"`
+	Line string `json:"line"`
+}
+
+// the most cells in the table used to find the longest common subsequence, above which the
+// lines that changed are shown as deleted and inserted in one go, so a huge body can't use up
+// all the memory. Lines that are the same at the start and end don't count towards it. At 8
+// bytes a cell that's a table of 2MB, e.g. 500 changed lines on each side.
+const maxDiffCells = 250_000
+
+// DiffRevisions compares two revisions, from may be nil to compare with an empty article
+func DiffRevisions(from, to *ArticleRevision) *RevisionDiff {
+	diff := &RevisionDiff{To: to.Number}
+
+	var previous ArticleRevision
+	if from != nil {
+		diff.From = &from.Number
+		previous = *from
+	}
+
+	diff.Title = diffLines(previous.Title, to.Title)
+	diff.Description = diffLines(previous.Description, to.Description)
+	diff.Body = diffLines(previous.Body, to.Body)
+
+	return diff
+}
+
+// diffLines finds the lines to delete from a and insert into it to turn it into b, by way of the
+// longest common subsequence of their lines
+func diffLines(a, b string) []DiffLine {
+	var as, bs []string
+	if a != "" {
+		as = strings.Split(a, "\n")
+	}
+	if b != "" {
+		bs = strings.Split(b, "\n")
+	}
+
+	// lines that haven't changed at either end don't need to go through the table
+	prefix := 0
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(as)-prefix && suffix < len(bs)-prefix && as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+
+	diff := make([]DiffLine, 0, len(as)+len(bs))
+	for _, line := range as[:prefix] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Line: line})
+	}
+	diff = append(diff, diffMiddle(as[prefix:len(as)-suffix], bs[prefix:len(bs)-suffix])...)
+	for _, line := range as[len(as)-suffix:] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Line: line})
+	}
+
+	return diff
+}
+
+func diffMiddle(as, bs []string) []DiffLine {
+	diff := make([]DiffLine, 0, len(as)+len(bs))
+
+	if len(as)*len(bs) > maxDiffCells {
+		for _, line := range as {
+			diff = append(diff, DiffLine{Op: DiffDelete, Line: line})
+		}
+		for _, line := range bs {
+			diff = append(diff, DiffLine{Op: DiffInsert, Line: line})
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of as[i:] and bs[j:]
+	width := len(bs) + 1
+	lcs := make([]int, (len(as)+1)*width)
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(as) && j < len(bs) {
+		switch {
+		case as[i] == bs[j]:
+			diff = append(diff, DiffLine{Op: DiffEqual, Line: as[i]})
+			i++
+			j++
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			diff = append(diff, DiffLine{Op: DiffDelete, Line: as[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffInsert, Line: bs[j]})
+			j++
+		}
+	}
+	for ; i < len(as); i++ {
+		diff = append(diff, DiffLine{Op: DiffDelete, Line: as[i]})
+	}
+	for ; j < len(bs); j++ {
+		diff = append(diff, DiffLine{Op: DiffInsert, Line: bs[j]})
+	}
+
+	return diff
+}
+
+// addArticleRevision records the article's content as it is now in the transaction, unless it is
+// the same as the latest revision, which is the case when only the tags or status were changed.
+func addArticleRevision(ctx context.Context, tx *sql.Tx, articleId int, restoredFrom *int) error {
+	query := `INSERT INTO ArticleRevision (ArticleId, Number, Title, Description, Body, CreatedAt, RestoredFrom)
+			  SELECT
+				a.ArticleId,
+				COALESCE((SELECT MAX(Number) FROM ArticleRevision WHERE ArticleId = a.ArticleId), 0) + 1,
+				a.Title,
+				a.Description,
+				a.Body,
+				a.UpdatedAt,
+				@restoredFrom
+			  FROM Article a
+			  WHERE a.ArticleId = @articleId
+			  AND NOT EXISTS (
+				SELECT 1 FROM ArticleRevision latest
+				WHERE latest.ArticleId = a.ArticleId
+				AND latest.Number = (SELECT MAX(Number) FROM ArticleRevision WHERE ArticleId = a.ArticleId)
+				AND latest.Title = a.Title AND latest.Description = a.Description AND latest.Body = a.Body
+				AND @restoredFrom IS NULL)`
+
+	_, err := tx.ExecContext(ctx, query, sql.Named("restoredFrom", restoredFrom), sql.Named("articleId", articleId))
+	if err != nil {
+		return fmt.Errorf("an error occurred when attempting to save an article revision: %w", err)
+	}
+
+	return nil
+}
+
+// GetRevisions lists the article's revisions, newest first
+func (repo *ArticleRepository) GetRevisions(articleId int) ([]*BodylessRevision, error) {
+	revisions := make([]*BodylessRevision, 0)
+
+	query := `SELECT Number, Title, Description, CreatedAt, RestoredFrom
+			  FROM ArticleRevision
+			  WHERE ArticleId = $1
+			  ORDER BY Number DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, articleId)
+	if err != nil {
+		return nil, fmt.Errorf("error querying article revisions: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var revision BodylessRevision
+		var createdAt string
+
+		err = rows.Scan(&revision.Number, &revision.Title, &revision.Description, &createdAt, &revision.RestoredFrom)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning article revision row: %w", err)
+		}
+
+		revision.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing created at date: %w", err)
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows while listing article revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func (repo *ArticleRepository) GetRevision(articleId, number int) (*ArticleRevision, error) {
+	query := `SELECT Number, Title, Description, Body, CreatedAt, RestoredFrom
+			  FROM ArticleRevision
+			  WHERE ArticleId = $1 AND Number = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var revision ArticleRevision
+	var createdAt string
+
+	err := repo.DB.QueryRowContext(ctx, query, articleId, number).Scan(
+		&revision.Number,
+		&revision.Title,
+		&revision.Description,
+		&revision.Body,
+		&createdAt,
+		&revision.RestoredFrom,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRevisionNotFound
+		default:
+			return nil, fmt.Errorf("error looking up article revision: %w", err)
+		}
+	}
+
+	revision.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created at date: %w", err)
+	}
+
+	return &revision, nil
+}
+
+// RestoreRevision puts the revision's title, description and body back, as an update of the
+// article at expectedVersion that is recorded as a new revision restored from the old one
+func (repo *ArticleRepository) RestoreRevision(revision *ArticleRevision, article *Article, userId, expectedVersion int) (*Article, error) {
+	var update UpdateArticleDTO
+	update.Article.Title = &revision.Title
+	update.Article.Description = &revision.Description
+	update.Article.Body = &revision.Body
+	update.Article.Status = &article.Status
+	update.Article.PublishAt = article.PublishAt
+
+	return repo.updateArticle(update, article.ArticleId, userId, expectedVersion, &revision.Number)
+}
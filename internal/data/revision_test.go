@@ -0,0 +1,142 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	eq := func(line string) DiffLine { return DiffLine{Op: DiffEqual, Line: line} }
+	ins := func(line string) DiffLine { return DiffLine{Op: DiffInsert, Line: line} }
+	del := func(line string) DiffLine { return DiffLine{Op: DiffDelete, Line: line} }
+
+	tests := []struct {
+		name string
+		a, b string
+		want []DiffLine
+	}{
+		{"both empty", "", "", []DiffLine{}},
+		{"unchanged", "a\nb", "a\nb", []DiffLine{eq("a"), eq("b")}},
+		{"from empty", "", "a\nb", []DiffLine{ins("a"), ins("b")}},
+		{"to empty", "a\nb", "", []DiffLine{del("a"), del("b")}},
+		{"changed line", "a\nb\nc", "a\nx\nc", []DiffLine{eq("a"), del("b"), ins("x"), eq("c")}},
+		{"inserted line", "a\nc", "a\nb\nc", []DiffLine{eq("a"), ins("b"), eq("c")}},
+		{"deleted line", "a\nb\nc", "a\nc", []DiffLine{eq("a"), del("b"), eq("c")}},
+		{"moved line", "a\nb\nc", "b\nc\na", []DiffLine{del("a"), eq("b"), eq("c"), ins("a")}},
+		{"trailing newline", "a", "a\n", []DiffLine{eq("a"), ins("")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	// every line differs apart from one in the middle, so the whole of both ends up in the
+	// table unless it is skipped
+	lines := func(n int) (string, string) {
+		var a, b []string
+		for i := 0; i < n; i++ {
+			a = append(a, "a"+strings.Repeat("x", i%7))
+			b = append(b, "b"+strings.Repeat("x", i%7))
+		}
+		a[n/2], b[n/2] = "same", "same"
+		return strings.Join(a, "\n"), strings.Join(b, "\n")
+	}
+
+	tests := []struct {
+		name      string
+		lines     int
+		wantEqual bool
+	}{
+		{"at the limit", 500, true},
+		{"over the limit", 501, false},
+		{"far over the limit", 2500, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffLines(lines(tt.lines))
+			want := 2 * tt.lines
+			if tt.wantEqual {
+				want--
+			}
+			if len(diff) != want {
+				t.Fatalf("got %d lines, want %d", len(diff), want)
+			}
+
+			equal := false
+			for i, line := range diff {
+				if line.Op == DiffEqual {
+					equal = true
+					continue
+				}
+				if !tt.wantEqual {
+					// replaced wholesale, the deletions all come before the insertions
+					want := DiffDelete
+					if i >= tt.lines {
+						want = DiffInsert
+					}
+					if line.Op != want {
+						t.Fatalf("got line %d as %s, want %s", i, line.Op, want)
+					}
+				}
+			}
+			if equal != tt.wantEqual {
+				t.Errorf("found the unchanged line: %v, want %v", equal, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	revision := func(number int, title, description, body string) *ArticleRevision {
+		return &ArticleRevision{
+			BodylessRevision: BodylessRevision{Number: number, Title: title, Description: description},
+			Body:             body,
+		}
+	}
+	one := 1
+
+	tests := []struct {
+		name     string
+		from, to *ArticleRevision
+		want     *RevisionDiff
+	}{
+		{
+			name: "first revision",
+			to:   revision(1, "Title", "Description", "Body"),
+			want: &RevisionDiff{
+				To:          1,
+				Title:       []DiffLine{{DiffInsert, "Title"}},
+				Description: []DiffLine{{DiffInsert, "Description"}},
+				Body:        []DiffLine{{DiffInsert, "Body"}},
+			},
+		},
+		{
+			name: "changed body",
+			from: revision(1, "Title", "Description", "Body"),
+			to:   revision(2, "Title", "Description", "New body"),
+			want: &RevisionDiff{
+				From:        &one,
+				To:          2,
+				Title:       []DiffLine{{DiffEqual, "Title"}},
+				Description: []DiffLine{{DiffEqual, "Description"}},
+				Body:        []DiffLine{{DiffDelete, "Body"}, {DiffInsert, "New body"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffRevisions(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got diff %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
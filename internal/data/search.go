@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"realworld.tayler.io/internal/validator"
+)
+
+// SearchResult is an article matching a search, with the part of it that matched
+type SearchResult struct {
+	BodylessArticle
+	// HTML with the matching terms wrapped in <mark>, everything else is escaped
+	Snippet string `json:"snippet"`
+}
+
+type SearchFilters struct {
+	// the search as typed by the user
+	Query string
+	// the search translated into an FTS5 query
+	match string
+	ArticleFilters
+}
+
+func (f *SearchFilters) ParseFilters(v *validator.Validator, r *http.Request) {
+	f.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+	f.match = ftsQuery(f.Query)
+
+	v.Check(f.Query != "", "q", "must not be blank")
+	v.Check(len(f.Query) <= 500, "q", "must not be more than 500 bytes long")
+	if f.Query != "" {
+		v.Check(f.match != "", "q", "must contain at least one word")
+	}
+
+	f.ArticleFilters.ParseFilters(v, r)
+
+	// results are ranked by relevance, so only offset pagination makes sense
+	v.Check(!r.URL.Query().Has("cursor"), "cursor", "is not supported when searching")
+	v.Check(!r.URL.Query().Has("order"), "order", "is not supported when searching")
+}
+
+// ftsQuery turns a search into an FTS5 query, supporting "quoted phrases" and prefix* terms.
+// Everything else is quoted so that FTS5 operators and punctuation in the search can never
+// cause a syntax error. Terms are implicitly ANDed together.
+func ftsQuery(search string) string {
+	var terms []string
+
+	for i, part := range strings.Split(search, `"`) {
+		// the parts alternate between being outside and inside quotes
+		if i%2 == 1 {
+			if phrase := ftsPhrase(strings.Fields(part)); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			prefix := strings.HasSuffix(word, "*")
+			term := ftsPhrase([]string{word})
+			if term == "" {
+				continue
+			}
+			if prefix {
+				term += "*"
+			}
+			terms = append(terms, term)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// ftsPhrase quotes words as a single phrase, dropping punctuation the tokenizer would ignore anyway
+func ftsPhrase(words []string) string {
+	cleaned := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) {
+				return r
+			}
+			return ' '
+		}, word)
+		cleaned = append(cleaned, strings.Fields(word)...)
+	}
+
+	if len(cleaned) == 0 {
+		return ""
+	}
+	return `"` + strings.Join(cleaned, " ") + `"`
+}
+
+// matchingArticles are the search matches with their rank and a snippet, for queries joining
+// them to articleFilterConditions, with the FTS5 query in @match. Title matches count the most,
+// then the description, then the body.
+const matchingArticles = `WITH Matches AS MATERIALIZED (
+				SELECT
+					rowid AS ArticleId,
+					bm25(ArticleSearch, 10.0, 5.0, 1.0) AS Rank,
+					snippet(ArticleSearch, -1, char(2), char(3), '…', 24) AS Snippet
+				FROM ArticleSearch
+				WHERE ArticleSearch MATCH @match
+			)
+			`
+
+// SearchArticles returns a page of the articles matching the search and the filters, best match first
+func (repo *ArticleRepository) SearchArticles(filters *SearchFilters, userId int) ([]*SearchResult, error) {
+	results := make([]*SearchResult, 0)
+
+	query := matchingArticles + `SELECT` + bodylessArticleColumns + `,
+				m.Snippet
+			FROM Matches m
+			JOIN Article a ON a.ArticleId = m.ArticleId` + articleFilterConditions + `
+			ORDER BY m.Rank, a.ArticleId DESC
+			LIMIT @limit OFFSET @offset`
+
+	args := []any{sql.Named("match", filters.match), sql.Named("userId", userId)}
+	args = append(args, filters.args()...)
+	args = append(args, sql.Named("limit", filters.Limit), sql.Named("offset", filters.Offset))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error searching articles: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippet string
+		article, err := scanBodylessArticle(rows, &snippet)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &SearchResult{BodylessArticle: *article, Snippet: highlightSnippet(snippet)})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows while searching articles: %w", err)
+	}
+
+	return results, nil
+}
+
+// CountSearch counts every article matching the search and the filters, stopping at limit if it is above zero
+func (repo *ArticleRepository) CountSearch(filters *SearchFilters, limit int) (int, error) {
+	query := matchingArticles + `SELECT COUNT(*) FROM (SELECT a.ArticleId
+			FROM Matches m
+			JOIN Article a ON a.ArticleId = m.ArticleId` + articleFilterConditions + `
+			LIMIT @limit)`
+
+	args := []any{sql.Named("match", filters.match)}
+	args = append(args, filters.args()...)
+	args = append(args, sql.Named("limit", countLimit(limit)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var count int
+	err := repo.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting search results: %w", err)
+	}
+
+	return count, nil
+}
+
+// highlightSnippet escapes the snippet for HTML, then swaps the markers FTS5 put around
+// each match for <mark> tags. The markers are control characters, which don't turn up in
+// real text and aren't touched by escaping.
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(html.EscapeString(snippet))
+}
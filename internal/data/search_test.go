@@ -0,0 +1,52 @@
+package data
+
+import "testing"
+
+func TestFtsQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		search string
+		want   string
+	}{
+		{"words", "go testing", `"go" "testing"`},
+		{"phrase", `"table driven" tests`, `"table driven" "tests"`},
+		{"prefix", "test*", `"test"*`},
+		{"operators are quoted", "go OR rust NOT java", `"go" "OR" "rust" "NOT" "java"`},
+		{"punctuation", "c++ (beginners) -tips", `"c" "beginners" "tips"`},
+		{"punctuation inside a word", "don't", `"don t"`},
+		{"unclosed quote", `"table driven`, `"table driven"`},
+		{"empty phrase", `"" go`, `"go"`},
+		{"only punctuation", "*** ???", ""},
+		{"other scripts", "привет 世界", `"привет" "世界"`},
+		{"blank", "   ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ftsQuery(tt.search); got != tt.want {
+				t.Errorf("ftsQuery(%q) = %q, want %q", tt.search, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{"plain", "just text", "just text"},
+		{"match", "a \x02match\x03 here", "a <mark>match</mark> here"},
+		{"escaped", "<script>\x02alert\x03</script>", "&lt;script&gt;<mark>alert</mark>&lt;/script&gt;"},
+		{"quotes", `"quoted" & 'single'`, "&#34;quoted&#34; &amp; &#39;single&#39;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.snippet); got != tt.want {
+				t.Errorf("highlightSnippet(%q) = %q, want %q", tt.snippet, got, tt.want)
+			}
+		})
+	}
+}
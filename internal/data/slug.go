@@ -0,0 +1,134 @@
+package data
+
+import (
+	"crypto/rand"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// long enough to be readable, short enough to leave room for a suffix in a sensible URL
+	maxSlugLength    = 80
+	slugSuffixLength = 6
+	// how many suffixes to try before giving up on a slug, a clash is very unlikely after the first
+	maxSlugAttempts = 5
+)
+
+// paths under /api/articles that would be routed somewhere else if an article had them as its
+// slug, articles that already had one were given a suffix by migration 000021
+var reservedSlugs = []string{"feed", "search", "trending"}
+
+// transliterations for letters that don't decompose into a base letter and accents
+var transliterations = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i", 'ħ': "h",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+}
+
+// Slugify turns a title into the human readable part of an article's URL. Accents are stripped
+// and Greek and Cyrillic are transliterated to ASCII. Letters, digits and the vowel signs that go
+// with them from other scripts, such as Chinese, Arabic, Hebrew or Hindi, are kept as they are so
+// those titles don't end up with the "article" fallback. Everything else becomes a dash. The
+// result is never empty.
+func Slugify(title string) string {
+	slug := truncateSlug(dashed(title), maxSlugLength-slugSuffixLength-1)
+	if slug == "" {
+		return "article"
+	}
+	return slug
+}
+
+// dashed lower cases and transliterates s the way Slugify does, joining the words with dashes
+func dashed(s string) string {
+	var b strings.Builder
+	dash := false
+	// whether the letter before is from a script whose marks are part of the word
+	keepMarks := false
+
+	// decomposing first splits letters like é into e and a combining accent, which is then dropped
+	for _, r := range norm.NFKD.String(strings.ToLower(s)) {
+		if unicode.IsMark(r) {
+			if keepMarks {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		keepMarks = false
+
+		if replacement, ok := transliterations[r]; ok {
+			if replacement != "" {
+				b.WriteString(replacement)
+				dash = false
+			}
+			continue
+		}
+
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+			dash = false
+			// e.g. the vowel signs of Hindi, without which the word is spelled differently
+			keepMarks = !unicode.In(r, unicode.Latin, unicode.Greek, unicode.Cyrillic, unicode.Common)
+			continue
+		}
+
+		if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	// composed again so that kept marks, such as the dakuten in が, are back on their letters
+	return norm.NFC.String(strings.TrimSuffix(b.String(), "-"))
+}
+
+// unreservedSlug gives a slug that is one of reservedSlugs a suffix, as if another article had it
+func unreservedSlug(slug string) string {
+	if slices.Contains(reservedSlugs, slug) {
+		return withSlugSuffix(slug)
+	}
+	return slug
+}
+
+// truncateSlug cuts the slug down to at most max runes, at a dash if there is one to cut at
+func truncateSlug(slug string, max int) string {
+	runes := []rune(slug)
+	if len(runes) <= max {
+		return slug
+	}
+
+	cut := string(runes[:max])
+	if i := strings.LastIndex(cut, "-"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimSuffix(cut, "-")
+}
+
+// withSlugSuffix makes a slug unique by adding a short random suffix, e.g. "hello-world-k3x9q2"
+func withSlugSuffix(slug string) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	suffix := make([]byte, slugSuffixLength)
+	rand.Read(suffix)
+	for i := range suffix {
+		suffix[i] = alphabet[int(suffix[i])%len(alphabet)]
+	}
+
+	return slug + "-" + string(suffix)
+}
+
+// hasSlugSuffix reports whether slug is base with a suffix from withSlugSuffix
+func hasSlugSuffix(slug, base string) bool {
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	return ok && len(suffix) == slugSuffixLength && strings.Trim(suffix, "abcdefghijklmnopqrstuvwxyz0123456789") == ""
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{"ascii", "Hello, World!", "hello-world"},
+		{"accents", "Crème brûlée à la française", "creme-brulee-a-la-francaise"},
+		{"transliterated latin", "Straße Ærø", "strasse-aero"},
+		{"greek", "Καλημέρα κόσμε", "kalimera-kosme"},
+		{"cyrillic", "Привет, мир", "privet-mir"},
+		{"chinese", "你好，世界", "你好-世界"},
+		{"japanese", "こんにちは 世界", "こんにちは-世界"},
+		{"japanese voiced marks", "ガイドブック", "ガイドブック"},
+		{"arabic", "مرحبا بالعالم", "مرحبا-بالعالم"},
+		{"hebrew", "שלום עולם", "שלום-עולם"},
+		{"hindi vowel signs", "नमस्ते दुनिया", "नमस्ते-दुनिया"},
+		{"digits", "Go 1.22 released", "go-1-22-released"},
+		{"surrounding punctuation", "  --Hello--  ", "hello"},
+		{"only punctuation", "???", "article"},
+		{"empty", "", "article"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Slugify(tt.title); got != tt.want {
+				t.Errorf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugifyLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{"words", strings.Repeat("word ", 40)},
+		{"one long word", strings.Repeat("a", 200)},
+		{"multibyte", strings.Repeat("世界 ", 60)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slug := Slugify(tt.title)
+			// room is left for a suffix from withSlugSuffix
+			if n := utf8.RuneCountInString(slug); n > maxSlugLength-slugSuffixLength-1 {
+				t.Errorf("got slug of %d runes, want at most %d", n, maxSlugLength-slugSuffixLength-1)
+			}
+			if strings.HasSuffix(slug, "-") {
+				t.Errorf("got slug %q ending in a dash", slug)
+			}
+		})
+	}
+}
+
+func TestSlugSuffix(t *testing.T) {
+	slug := withSlugSuffix("hello-world")
+
+	tests := []struct {
+		name string
+		slug string
+		base string
+		want bool
+	}{
+		{"suffixed", slug, "hello-world", true},
+		{"unsuffixed", "hello-world", "hello-world", false},
+		{"other base", slug, "hello", false},
+		{"suffix too short", "hello-world-k3x9", "hello-world", false},
+		{"upper case suffix", "hello-world-K3X9Q2", "hello-world", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasSlugSuffix(tt.slug, tt.base); got != tt.want {
+				t.Errorf("hasSlugSuffix(%q, %q) = %v, want %v", tt.slug, tt.base, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnreservedSlug(t *testing.T) {
+	tests := []struct {
+		slug     string
+		reserved bool
+	}{
+		{"feed", true},
+		{"search", true},
+		{"trending", true},
+		{"feed-me", false},
+		{"hello-world", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			got := unreservedSlug(tt.slug)
+			switch {
+			case tt.reserved && !hasSlugSuffix(got, tt.slug):
+				t.Errorf("unreservedSlug(%q) = %q, want it with a suffix", tt.slug, got)
+			case !tt.reserved && got != tt.slug:
+				t.Errorf("unreservedSlug(%q) = %q, want it unchanged", tt.slug, got)
+			}
+		})
+	}
+}
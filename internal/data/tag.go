@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -12,10 +13,21 @@ type TagRepository struct {
 	TimeoutSeconds int
 }
 
+// GetAllTags returns the tags on published articles by users that haven't been deleted, so that
+// drafts and scheduled articles don't give away what their authors are writing about
 func (repo *TagRepository) GetAllTags() ([]string, error) {
 	tags := make([]string, 0)
 
-	query := `SELECT DISTINCT tag FROM Tag ORDER BY TagId ASC`
+	query := `SELECT t.Tag
+			  FROM Tag t
+			  WHERE EXISTS (
+				SELECT 1 FROM ArticleTag at
+				JOIN Article a ON a.ArticleId = at.ArticleId
+				JOIN User u ON u.UserId = a.UserId
+				WHERE at.TagId = t.TagId
+				AND a.Status = 'published'
+				AND u.DeletedAt IS NULL)
+			  ORDER BY t.TagId ASC`
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
@@ -49,3 +61,24 @@ func (repo *TagRepository) GetAllTags() ([]string, error) {
 
 	return tags, nil
 }
+
+// GetTagsModifiedAt returns when the list returned by GetAllTags last changed
+func (repo *TagRepository) GetTagsModifiedAt() (time.Time, error) {
+	query := `SELECT ModifiedAt FROM TagsModified`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var modifiedAt *string
+	err := repo.DB.QueryRowContext(ctx, query).Scan(&modifiedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error looking up when the tags were modified: %w", err)
+	}
+
+	t, err := parseModifiedAt(modifiedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing modified at date: %w", err)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,219 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"realworld.tayler.io/internal/validator"
+)
+
+// how much each kind of interaction counts towards an article trending, before it decays
+var trendingWeights = map[string]float64{
+	"view":     1,
+	"comment":  4,
+	"favorite": 6,
+}
+
+type TrendingFilters struct {
+	ArticleFilters
+}
+
+func (f *TrendingFilters) ParseFilters(v *validator.Validator, r *http.Request) {
+	f.ArticleFilters.ParseFilters(v, r)
+
+	// scores change every time they are recomputed, so only offset pagination makes sense
+	v.Check(!r.URL.Query().Has("cursor"), "cursor", "is not supported for trending articles")
+	v.Check(!r.URL.Query().Has("order"), "order", "is not supported for trending articles")
+}
+
+// RecordView counts a view of the article towards it trending. Views are counted once an hour
+// for each viewer, so refreshing the page over and over doesn't make an article trend.
+func (repo *ArticleRepository) RecordView(articleId int, viewer string, at time.Time) error {
+	query := `INSERT OR IGNORE INTO ArticleView (ArticleId, Viewer, ViewedAt) VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	_, err := repo.DB.ExecContext(ctx, query, articleId, viewer, at.UTC().Truncate(time.Hour).Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("error recording article view: %w", err)
+	}
+
+	return nil
+}
+
+// trendingScore is how much count interactions of a kind that happened ageHours ago count
+// towards an article trending, halving every halfLife
+func trendingScore(kind string, count, ageHours int, halfLife time.Duration) float64 {
+	// from the middle of the hour, so that the last hour doesn't count in full
+	decay := math.Exp2(-(float64(ageHours) + 0.5) / halfLife.Hours())
+	return trendingWeights[kind] * float64(count) * decay
+}
+
+// ComputeTrendingScores replaces every article's trending score with one worked out from the views,
+// favorites and comments it had in the window before now. Each one counts for less the older it
+// is, halving every halfLife. Interactions by the article's author don't count, and neither do
+// views that have dropped out of the window, which are deleted. It returns how many articles
+// have a score.
+func (repo *ArticleRepository) ComputeTrendingScores(now time.Time, window, halfLife time.Duration) (n int, retErr error) {
+	// the events are counted in hours, which is as precise as views are recorded. Comparing the
+	// times as text is only out for events in the same second as the cutoff, and lets the indexes
+	// on them be used.
+	query := `WITH Events AS (
+				SELECT ArticleId, NULL AS UserId, ViewedAt AS At, 'view' AS Kind FROM ArticleView WHERE ViewedAt >= $1
+				UNION ALL
+				SELECT ArticleId, UserId, CreatedAt, 'favorite' FROM ArticleFavorite WHERE CreatedAt >= $1
+				UNION ALL
+				SELECT ArticleId, UserId, CreatedAt, 'comment' FROM Comment WHERE CreatedAt >= $1
+			  )
+			  SELECT
+				e.ArticleId,
+				e.Kind,
+				MAX(CAST((julianday($2) - julianday(e.At)) * 24 AS INTEGER), 0) AS AgeHours,
+				COUNT(*)
+			  FROM Events e
+			  JOIN Article a ON a.ArticleId = e.ArticleId
+			  WHERE a.Status = 'published'
+			  AND (e.UserId IS NULL OR e.UserId <> a.UserId)
+			  GROUP BY e.ArticleId, e.Kind, AgeHours`
+
+	cutoff := now.Add(-window).UTC().Format(time.RFC3339Nano)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, cutoff, now.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, fmt.Errorf("error querying article interactions: %w", err)
+	}
+
+	defer rows.Close()
+
+	scores := make(map[int]float64)
+
+	for rows.Next() {
+		var articleId, ageHours, count int
+		var kind string
+
+		err = rows.Scan(&articleId, &kind, &ageHours, &count)
+		if err != nil {
+			return 0, fmt.Errorf("error scanning article interactions row: %w", err)
+		}
+
+		scores[articleId] += trendingScore(kind, count, ageHours, halfLife)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over rows while computing trending scores: %w", err)
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when starting a transaction while saving trending scores: %w", err)
+		return 0, retErr
+	}
+	defer func() {
+		if retErr != nil {
+			err = tx.Rollback()
+			if err != nil && !errors.Is(err, sql.ErrTxDone) {
+				repo.Log.ErrorContext(ctx, err.Error())
+			}
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM ArticleScore`)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when clearing trending scores: %w", err)
+		return 0, retErr
+	}
+
+	for articleId, score := range scores {
+		_, err = tx.ExecContext(ctx, `INSERT INTO ArticleScore (ArticleId, Score) VALUES ($1, $2)`, articleId, score)
+		if err != nil {
+			retErr = fmt.Errorf("an error occurred when saving a trending score: %w", err)
+			return 0, retErr
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM ArticleView WHERE ViewedAt < $1`, cutoff)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when deleting old article views: %w", err)
+		return 0, retErr
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when attempting to commit the transaction while saving trending scores: %w", err)
+		return 0, retErr
+	}
+
+	return len(scores), nil
+}
+
+// GetTrendingArticles returns a page of the articles with a trending score that match the
+// filters, highest score first
+func (repo *ArticleRepository) GetTrendingArticles(filters *TrendingFilters, userId int) ([]*BodylessArticle, error) {
+	articles := make([]*BodylessArticle, 0)
+
+	query := `SELECT` + bodylessArticleColumns + `
+			FROM ArticleScore s
+			JOIN Article a ON a.ArticleId = s.ArticleId` + articleFilterConditions + `
+			ORDER BY s.Score DESC, a.ArticleId DESC
+			LIMIT @limit OFFSET @offset`
+
+	args := []any{sql.Named("userId", userId)}
+	args = append(args, filters.args()...)
+	args = append(args, sql.Named("limit", filters.Limit), sql.Named("offset", filters.Offset))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying trending articles: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		article, err := scanBodylessArticle(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		articles = append(articles, article)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows while listing trending articles: %w", err)
+	}
+
+	return articles, nil
+}
+
+// CountTrending counts the articles with a trending score that match the filters, stopping at limit if it is above zero
+func (repo *ArticleRepository) CountTrending(filters *TrendingFilters, limit int) (int, error) {
+	query := `SELECT COUNT(*) FROM (SELECT a.ArticleId
+			FROM ArticleScore s
+			JOIN Article a ON a.ArticleId = s.ArticleId` + articleFilterConditions + `
+			LIMIT @limit)`
+
+	args := filters.args()
+	args = append(args, sql.Named("limit", countLimit(limit)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var count int
+	err := repo.DB.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting trending articles: %w", err)
+	}
+
+	return count, nil
+}
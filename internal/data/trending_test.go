@@ -0,0 +1,49 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrendingScore(t *testing.T) {
+	const halfLife = 24 * time.Hour
+	// what a single view in the last hour is worth
+	fresh := math.Exp2(-0.5 / 24)
+
+	tests := []struct {
+		name     string
+		kind     string
+		count    int
+		ageHours int
+		want     float64
+	}{
+		{"view", "view", 1, 0, fresh},
+		{"several views", "view", 3, 0, 3 * fresh},
+		{"comment", "comment", 1, 0, 4 * fresh},
+		{"favorite", "favorite", 1, 0, 6 * fresh},
+		{"one half life", "view", 1, 24, fresh / 2},
+		{"two half lives", "favorite", 2, 48, 2 * 6 * fresh / 4},
+		{"unknown kind", "share", 5, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trendingScore(tt.kind, tt.count, tt.ageHours, halfLife)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("trendingScore(%q, %d, %d) = %v, want %v", tt.kind, tt.count, tt.ageHours, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrendingScoreDecays(t *testing.T) {
+	previous := math.Inf(1)
+	for ageHours := 0; ageHours < 24*7; ageHours++ {
+		score := trendingScore("favorite", 1, ageHours, 6*time.Hour)
+		if score <= 0 || score >= previous {
+			t.Fatalf("got score %v at %d hours after %v, want it positive and lower", score, ageHours, previous)
+		}
+		previous = score
+	}
+}
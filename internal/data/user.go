@@ -5,9 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"realworld.tayler.io/internal/validator"
 )
 
@@ -19,6 +19,11 @@ type User struct {
 	Bio      string   `json:"bio"`
 	Image    *string  `json:"image"`
 	Password Password `json:"-"`
+	Version  int      `json:"-"`
+	// tokens issued under an earlier version are no longer honoured
+	TokenVersion int `json:"-"`
+	// when the user or who follows them last changed, zero if that isn't known
+	ModifiedAt time.Time `json:"-"`
 }
 
 type Profile struct {
@@ -53,6 +58,7 @@ var (
 type UserRepository struct {
 	DB             *sql.DB
 	TimeoutSeconds int
+	Log            *slog.Logger
 }
 
 func (repo *UserRepository) RegisterUser(user *User) (*User, error) {
@@ -79,9 +85,19 @@ func (repo *UserRepository) RegisterUser(user *User) (*User, error) {
 }
 
 func (repo *UserRepository) GetUserByCredentials(email string, password string) (*User, error) {
+	return repo.getUserByCredentials(email, password, false)
+}
+
+// GetDeletedUserByCredentials is the counterpart of GetUserByCredentials for accounts
+// that are pending deletion, so that they can prove ownership in order to restore them
+func (repo *UserRepository) GetDeletedUserByCredentials(email string, password string) (*User, error) {
+	return repo.getUserByCredentials(email, password, true)
+}
+
+func (repo *UserRepository) getUserByCredentials(email string, password string, deleted bool) (*User, error) {
 
-	query := `SELECT UserId, Username, Bio, Image, PasswordHash FROM User WHERE Email = $1`
-	args := []any{email}
+	query := `SELECT UserId, Username, Bio, Image, PasswordHash, TokenVersion FROM User WHERE Email = $1 AND (DeletedAt IS NOT NULL) = $2`
+	args := []any{email, deleted}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
@@ -96,6 +112,7 @@ func (repo *UserRepository) GetUserByCredentials(email string, password string)
 		&user.Bio,
 		&user.Image,
 		&user.Password.hash,
+		&user.TokenVersion,
 	)
 	if err != nil {
 		switch {
@@ -106,21 +123,19 @@ func (repo *UserRepository) GetUserByCredentials(email string, password string)
 		}
 	}
 
-	err = bcrypt.CompareHashAndPassword(user.Password.hash, []byte(password))
+	matches, err := user.Password.Matches(password)
 	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return nil, ErrInvalidCredentials
-		default:
-			return nil, fmt.Errorf("error when attempting to compare password and password hash: %w", err)
-		}
+		return nil, fmt.Errorf("error when attempting to compare password and password hash: %w", err)
+	}
+	if !matches {
+		return nil, ErrInvalidCredentials
 	}
 
 	return user, nil
 }
 
 func (repo *UserRepository) GetUserById(userId int) (*User, error) {
-	query := `SELECT Username, Email, Bio, Image, PasswordHash FROM User WHERE UserId = $1`
+	query := `SELECT Username, Email, Bio, Image, PasswordHash, Version FROM User WHERE UserId = $1 AND DeletedAt IS NULL`
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
 
@@ -134,6 +149,7 @@ func (repo *UserRepository) GetUserById(userId int) (*User, error) {
 		&user.Bio,
 		&user.Image,
 		&user.Password.hash,
+		&user.Version,
 	)
 	if err != nil {
 		switch {
@@ -148,7 +164,7 @@ func (repo *UserRepository) GetUserById(userId int) (*User, error) {
 }
 
 func (repo *UserRepository) GetUserByUsername(username string) (*User, error) {
-	query := `SELECT UserId, Email, Bio, Image, PasswordHash FROM User WHERE Username = $1`
+	query := `SELECT UserId, Email, Bio, Image, PasswordHash, ModifiedAt FROM User WHERE Username = $1 AND DeletedAt IS NULL`
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
 	defer cancel()
 
@@ -156,12 +172,14 @@ func (repo *UserRepository) GetUserByUsername(username string) (*User, error) {
 		Username: username,
 	}
 
+	var modifiedAt *string
 	err := repo.DB.QueryRowContext(ctx, query, username).Scan(
 		&user.UserId,
 		&user.Email,
 		&user.Bio,
 		&user.Image,
 		&user.Password.hash,
+		&modifiedAt,
 	)
 	if err != nil {
 		switch {
@@ -172,11 +190,18 @@ func (repo *UserRepository) GetUserByUsername(username string) (*User, error) {
 		}
 	}
 
+	user.ModifiedAt, err = parseModifiedAt(modifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing modified at date: %w", err)
+	}
+
 	return user, nil
 }
 
+// UpdateUser only applies the update if the user is still at the version it was read at,
+// otherwise ErrEditConflict is returned. On success the user's version is bumped to match.
 func (repo *UserRepository) UpdateUser(user *User) error {
-	query := `UPDATE User SET (Username, Email, PasswordHash, Bio, Image) = ($1, $2, $3, $4, $5) WHERE UserId = $6`
+	query := `UPDATE User SET (Username, Email, PasswordHash, Bio, Image, Version) = ($1, $2, $3, $4, $5, Version + 1) WHERE UserId = $6 AND Version = $7`
 	args := []any{
 		user.Username,
 		user.Email,
@@ -184,6 +209,7 @@ func (repo *UserRepository) UpdateUser(user *User) error {
 		user.Bio,
 		user.Image,
 		user.UserId,
+		user.Version,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
@@ -200,10 +226,14 @@ func (repo *UserRepository) UpdateUser(user *User) error {
 			return fmt.Errorf("error updating user: %w", err)
 		}
 	}
-	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
-		return fmt.Errorf("error updating user - no rows were updated: %w", err)
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("error updating user: %w", err)
+	} else if rows == 0 {
+		return ErrEditConflict
 	}
 
+	user.Version++
+
 	return nil
 }
 
@@ -257,3 +287,260 @@ func (repo *UserRepository) Unfollow(userId, followUserId int) error {
 
 	return nil
 }
+
+// IsActive reports whether the user exists, has not requested deletion of their account and
+// still honours tokens issued under tokenVersion. Tokens belonging to inactive users must not be honoured.
+func (repo *UserRepository) IsActive(userId int, tokenVersion int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM User WHERE UserId = $1 AND DeletedAt IS NULL AND TokenVersion = $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var isActive bool
+	err := repo.DB.QueryRowContext(ctx, query, userId, tokenVersion).Scan(&isActive)
+	if err != nil {
+		return false, fmt.Errorf("error checking whether user is active: %w", err)
+	}
+
+	return isActive, nil
+}
+
+// MarkUserDeleted starts the grace period for an account deletion. The account is hidden and its
+// tokens stop working straight away, and for good, even if the account is restored. Nothing is
+// removed until PurgeDeletedUsers runs.
+func (repo *UserRepository) MarkUserDeleted(userId int, deletedAt time.Time) error {
+	query := `UPDATE User SET DeletedAt = $1, TokenVersion = TokenVersion + 1 WHERE UserId = $2 AND DeletedAt IS NULL`
+	args := []any{deletedAt.UTC().Format(time.RFC3339Nano), userId}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	result, err := repo.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error marking user as deleted: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RestoreUser cancels a pending deletion, provided it was requested after notBefore
+func (repo *UserRepository) RestoreUser(userId int, notBefore time.Time) error {
+	query := `UPDATE User SET DeletedAt = NULL WHERE UserId = $1 AND DeletedAt IS NOT NULL AND julianday(DeletedAt) >= julianday($2)`
+	args := []any{userId, notBefore.UTC().Format(time.RFC3339Nano)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	result, err := repo.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error restoring user: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DeleteUser permanently removes a user. Their follows, articles, comments and favorites
+// (as well as everything hanging off their articles) are removed by the ON DELETE CASCADE
+// rules on the foreign keys, after which any tags that are no longer in use are removed too.
+func (repo *UserRepository) DeleteUser(userId int) (retErr error) {
+	deleteUserQuery := `DELETE FROM User WHERE UserId = $1`
+	deleteOrphanedTagsQuery := `DELETE FROM Tag WHERE NOT EXISTS (SELECT 1 FROM ArticleTag at WHERE at.TagId = Tag.TagId)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when starting a transaction while attempting to delete a user: %w", err)
+		return retErr
+	}
+	defer func() {
+		if retErr != nil {
+			err = tx.Rollback()
+			if err != nil && !errors.Is(err, sql.ErrTxDone) {
+				repo.Log.ErrorContext(ctx, err.Error())
+			}
+		}
+	}()
+
+	// the cascades silently do nothing if foreign keys aren't enforced on this connection,
+	// which would leave the user's content orphaned, so refuse to continue in that case
+	var foreignKeysEnabled bool
+	err = tx.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&foreignKeysEnabled)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred while checking foreign key enforcement: %w", err)
+		return retErr
+	}
+	if !foreignKeysEnabled {
+		retErr = errors.New("foreign keys must be enabled to delete a user")
+		return retErr
+	}
+
+	result, err := tx.ExecContext(ctx, deleteUserQuery, userId)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred while trying to delete a user: %w", err)
+		return retErr
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		retErr = ErrUserNotFound
+		return retErr
+	}
+
+	_, err = tx.ExecContext(ctx, deleteOrphanedTagsQuery)
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred while trying to delete orphaned tags: %w", err)
+		return retErr
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		retErr = fmt.Errorf("an error occurred when attempting to commit the transaction while deleting a user: %w", err)
+		return retErr
+	}
+
+	return retErr
+}
+
+// PurgeDeletedUsers permanently removes every user whose deletion was requested before the cutoff
+func (repo *UserRepository) PurgeDeletedUsers(cutoff time.Time) (int, error) {
+	query := `SELECT UserId FROM User WHERE DeletedAt IS NOT NULL AND julianday(DeletedAt) < julianday($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, cutoff.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, fmt.Errorf("error querying users pending deletion: %w", err)
+	}
+	defer rows.Close()
+
+	userIds := make([]int, 0)
+	for rows.Next() {
+		var userId int
+		if err = rows.Scan(&userId); err != nil {
+			return 0, fmt.Errorf("error scanning user pending deletion: %w", err)
+		}
+		userIds = append(userIds, userId)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over users pending deletion: %w", err)
+	}
+
+	purged := 0
+	for _, userId := range userIds {
+		err = repo.DeleteUser(userId)
+		if err != nil {
+			// already gone, e.g. removed by another instance running the same job
+			if errors.Is(err, ErrUserNotFound) {
+				continue
+			}
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// GetFollowers returns the profiles of everyone following the given user
+func (repo *UserRepository) GetFollowers(userId int) ([]*Profile, error) {
+	query := `SELECT
+				u.Username,
+				u.Bio,
+				u.Image,
+				EXISTS (SELECT 1 FROM Follower WHERE UserId = $1 AND FollowUserId = u.UserId) AS Following
+			  FROM Follower f
+			  JOIN User u ON u.UserId = f.UserId
+			  WHERE f.FollowUserId = $1
+			  AND u.DeletedAt IS NULL
+			  ORDER BY u.Username ASC`
+
+	return repo.getProfiles(query, userId)
+}
+
+// GetFollowing returns the profiles of everyone the given user follows
+func (repo *UserRepository) GetFollowing(userId int) ([]*Profile, error) {
+	query := `SELECT
+				u.Username,
+				u.Bio,
+				u.Image,
+				1 AS Following
+			  FROM Follower f
+			  JOIN User u ON u.UserId = f.FollowUserId
+			  WHERE f.UserId = $1
+			  AND u.DeletedAt IS NULL
+			  ORDER BY u.Username ASC`
+
+	return repo.getProfiles(query, userId)
+}
+
+func (repo *UserRepository) getProfiles(query string, args ...any) ([]*Profile, error) {
+	profiles := make([]*Profile, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying profiles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var profile Profile
+
+		err = rows.Scan(&profile.Username, &profile.Bio, &profile.Image, &profile.Following)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning profile row: %w", err)
+		}
+
+		profiles = append(profiles, &profile)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows while fetching profiles: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// CountUserContent returns how many articles, comments and favorites belong to the user,
+// which is a reasonable proxy for how large an export of their data will be
+func (repo *UserRepository) CountUserContent(userId int) (int, error) {
+	query := `SELECT
+				(SELECT COUNT(*) FROM Article WHERE UserId = $1) +
+				(SELECT COUNT(*) FROM Comment WHERE UserId = $1) +
+				(SELECT COUNT(*) FROM ArticleFavorite WHERE UserId = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var count int
+	err := repo.DB.QueryRowContext(ctx, query, userId).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting user content: %w", err)
+	}
+
+	return count, nil
+}
+
+func (repo *UserRepository) IsAdmin(userId int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM User WHERE UserId = $1 AND IsAdmin = 1 AND DeletedAt IS NULL)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(repo.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var isAdmin bool
+	err := repo.DB.QueryRowContext(ctx, query, userId).Scan(&isAdmin)
+	if err != nil {
+		return false, fmt.Errorf("error checking whether user is an admin: %w", err)
+	}
+
+	return isAdmin, nil
+}
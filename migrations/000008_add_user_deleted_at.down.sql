@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+ALTER TABLE User DROP COLUMN DeletedAt;
@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE User ADD COLUMN DeletedAt TEXT;
+
+CREATE INDEX idx_users_deleted_at ON User (DeletedAt);
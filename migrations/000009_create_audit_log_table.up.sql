@@ -0,0 +1,27 @@
+PRAGMA foreign_keys = ON;
+
+-- there is deliberately no foreign key on ActorUserId, the audit trail
+-- has to outlive the accounts that appear in it
+CREATE TABLE AuditLog (
+    AuditLogId INTEGER NOT NULL PRIMARY KEY,
+    ActorUserId INTEGER,
+    ActorUsername TEXT,
+    Action TEXT NOT NULL,
+    TargetType TEXT,
+    TargetId TEXT,
+    Ip TEXT,
+    UserAgent TEXT,
+    RequestId TEXT,
+    CreatedAt TEXT NOT NULL
+);
+
+CREATE INDEX idx_audit_log_created_at ON AuditLog (CreatedAt);
+CREATE INDEX idx_audit_log_actor_user_id ON AuditLog (ActorUserId);
+CREATE INDEX idx_audit_log_action ON AuditLog (Action);
+
+-- rows may only be deleted by the retention job, never modified
+CREATE TRIGGER trg_audit_log_append_only
+BEFORE UPDATE ON AuditLog
+BEGIN
+    SELECT RAISE(ABORT, 'AuditLog is append-only');
+END;
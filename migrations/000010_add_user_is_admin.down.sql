@@ -0,0 +1,3 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE User DROP COLUMN IsAdmin;
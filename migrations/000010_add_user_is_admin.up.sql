@@ -0,0 +1,3 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE User ADD COLUMN IsAdmin INTEGER NOT NULL DEFAULT 0;
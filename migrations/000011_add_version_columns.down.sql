@@ -0,0 +1,4 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE Article DROP COLUMN Version;
+ALTER TABLE User DROP COLUMN Version;
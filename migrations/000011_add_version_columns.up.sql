@@ -0,0 +1,4 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE Article ADD COLUMN Version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE User ADD COLUMN Version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+PRAGMA foreign_keys = ON;
+
+DROP TABLE IF EXISTS IdempotencyKey;
@@ -0,0 +1,15 @@
+PRAGMA foreign_keys = ON;
+
+CREATE TABLE IdempotencyKey (
+    UserId INTEGER NOT NULL,
+    Key TEXT NOT NULL,
+    RequestHash TEXT NOT NULL,
+    ResponseStatus INTEGER NOT NULL,
+    ResponseHeaders TEXT NOT NULL,
+    ResponseBody BLOB NOT NULL,
+    CreatedAt TEXT NOT NULL,
+    PRIMARY KEY (UserId, Key),
+    FOREIGN KEY (UserId) REFERENCES User (UserId) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_idempotency_keys_created_at ON IdempotencyKey (CreatedAt);
@@ -0,0 +1,4 @@
+PRAGMA foreign_keys = ON;
+
+DROP INDEX IF EXISTS idx_comments_article_id;
+DROP INDEX IF EXISTS idx_articles_updated_at;
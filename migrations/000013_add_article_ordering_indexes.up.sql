@@ -0,0 +1,4 @@
+PRAGMA foreign_keys = ON;
+
+CREATE INDEX idx_articles_updated_at ON Article (UpdatedAt, ArticleId);
+CREATE INDEX idx_comments_article_id ON Comment (ArticleId);
@@ -0,0 +1,6 @@
+PRAGMA foreign_keys = ON;
+
+DROP TRIGGER IF EXISTS trg_article_search_update;
+DROP TRIGGER IF EXISTS trg_article_search_delete;
+DROP TRIGGER IF EXISTS trg_article_search_insert;
+DROP TABLE IF EXISTS ArticleSearch;
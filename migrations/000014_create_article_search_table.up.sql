@@ -0,0 +1,37 @@
+PRAGMA foreign_keys = ON;
+
+-- an external content table, the text itself is only stored once in Article
+CREATE VIRTUAL TABLE ArticleSearch USING fts5(
+    Title,
+    Description,
+    Body,
+    content = 'Article',
+    content_rowid = 'ArticleId',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER trg_article_search_insert
+AFTER INSERT ON Article
+BEGIN
+    INSERT INTO ArticleSearch (rowid, Title, Description, Body)
+    VALUES (new.ArticleId, new.Title, new.Description, new.Body);
+END;
+
+CREATE TRIGGER trg_article_search_delete
+AFTER DELETE ON Article
+BEGIN
+    INSERT INTO ArticleSearch (ArticleSearch, rowid, Title, Description, Body)
+    VALUES ('delete', old.ArticleId, old.Title, old.Description, old.Body);
+END;
+
+CREATE TRIGGER trg_article_search_update
+AFTER UPDATE OF Title, Description, Body ON Article
+BEGIN
+    INSERT INTO ArticleSearch (ArticleSearch, rowid, Title, Description, Body)
+    VALUES ('delete', old.ArticleId, old.Title, old.Description, old.Body);
+    INSERT INTO ArticleSearch (rowid, Title, Description, Body)
+    VALUES (new.ArticleId, new.Title, new.Description, new.Body);
+END;
+
+-- index the articles that already exist
+INSERT INTO ArticleSearch (ArticleSearch) VALUES ('rebuild');
@@ -0,0 +1,6 @@
+PRAGMA foreign_keys = ON;
+
+DROP TRIGGER IF EXISTS trg_article_slug_history_reserve_update;
+DROP TRIGGER IF EXISTS trg_article_slug_history_reserve_insert;
+DROP TRIGGER IF EXISTS trg_article_slug_history_update;
+DROP TABLE IF EXISTS ArticleSlugHistory;
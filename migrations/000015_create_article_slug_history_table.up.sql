@@ -0,0 +1,33 @@
+PRAGMA foreign_keys = ON;
+
+-- slugs articles have had before their title changed, so that old links keep working
+CREATE TABLE ArticleSlugHistory (
+    Slug TEXT NOT NULL PRIMARY KEY,
+    ArticleId INTEGER NOT NULL,
+    CreatedAt TEXT NOT NULL,
+    FOREIGN KEY (ArticleId) REFERENCES Article (ArticleId) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_article_slug_history_article_id ON ArticleSlugHistory (ArticleId);
+
+CREATE TRIGGER trg_article_slug_history_update AFTER UPDATE OF Slug ON Article
+WHEN old.Slug <> new.Slug
+BEGIN
+    INSERT INTO ArticleSlugHistory (Slug, ArticleId, CreatedAt) VALUES (old.Slug, new.ArticleId, new.UpdatedAt);
+    -- an article going back to one of its old slugs makes it current again
+    DELETE FROM ArticleSlugHistory WHERE Slug = new.Slug;
+END;
+
+-- an old slug stays with its article, so another article taking it is treated the same as taking
+-- a current slug, which makes the repository retry with a suffix
+CREATE TRIGGER trg_article_slug_history_reserve_insert BEFORE INSERT ON Article
+WHEN EXISTS (SELECT 1 FROM ArticleSlugHistory WHERE Slug = new.Slug)
+BEGIN
+    SELECT RAISE(ABORT, 'UNIQUE constraint failed: Article.Slug');
+END;
+
+CREATE TRIGGER trg_article_slug_history_reserve_update BEFORE UPDATE OF Slug ON Article
+WHEN EXISTS (SELECT 1 FROM ArticleSlugHistory WHERE Slug = new.Slug AND ArticleId <> new.ArticleId)
+BEGIN
+    SELECT RAISE(ABORT, 'UNIQUE constraint failed: Article.Slug');
+END;
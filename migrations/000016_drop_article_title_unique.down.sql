@@ -0,0 +1,95 @@
+PRAGMA foreign_keys = ON;
+
+-- SQLite can't add a UNIQUE constraint, so the table is rebuilt with it. This fails if two
+-- articles have come to share a title since the up migration.
+--
+-- golang-migrate runs each migration in a transaction, where PRAGMA foreign_keys does nothing, so
+-- foreign keys are enforced or not depending on the connection running the migration. When they
+-- are, dropping the old table deletes every comment, tag, favorite and old slug of its articles
+-- through ON DELETE CASCADE. Those rows are copied beforehand and put back once the new table is
+-- in place, which works whether or not the cascade happened.
+CREATE TEMP TABLE SavedComment AS SELECT * FROM Comment;
+CREATE TEMP TABLE SavedArticleTag AS SELECT * FROM ArticleTag;
+CREATE TEMP TABLE SavedArticleFavorite AS SELECT * FROM ArticleFavorite;
+CREATE TEMP TABLE SavedArticleSlugHistory AS SELECT * FROM ArticleSlugHistory;
+
+CREATE TABLE ArticleRebuilt (
+    ArticleId INTEGER NOT NULL PRIMARY KEY,
+    UserId INTEGER NOT NULL,
+    Title TEXT NOT NULL UNIQUE,
+    Slug TEXT NOT NULL UNIQUE,
+    Description TEXT NOT NULL,
+    Body TEXT NOT NULL,
+    CreatedAt TEXT NOT NULL,
+    UpdatedAt TEXT NOT NULL,
+    Version INTEGER NOT NULL DEFAULT 1,
+    FOREIGN KEY (UserId) REFERENCES User(UserId) ON DELETE CASCADE
+);
+
+INSERT INTO ArticleRebuilt (ArticleId, UserId, Title, Slug, Description, Body, CreatedAt, UpdatedAt, Version)
+SELECT ArticleId, UserId, Title, Slug, Description, Body, CreatedAt, UpdatedAt, Version FROM Article;
+
+DROP TABLE Article;
+ALTER TABLE ArticleRebuilt RENAME TO Article;
+
+-- when foreign keys aren't enforced the rows were never deleted, so they are cleared out first to
+-- put the copies back without duplicating them
+DELETE FROM Comment;
+DELETE FROM ArticleTag;
+DELETE FROM ArticleFavorite;
+DELETE FROM ArticleSlugHistory;
+INSERT INTO Comment SELECT * FROM SavedComment;
+INSERT INTO ArticleTag SELECT * FROM SavedArticleTag;
+INSERT INTO ArticleFavorite SELECT * FROM SavedArticleFavorite;
+INSERT INTO ArticleSlugHistory SELECT * FROM SavedArticleSlugHistory;
+
+DROP TABLE SavedComment;
+DROP TABLE SavedArticleTag;
+DROP TABLE SavedArticleFavorite;
+DROP TABLE SavedArticleSlugHistory;
+
+-- indexes and triggers are dropped with the old table, so the ones on Article are created again
+-- from 000003_create_articles_table and 000013_add_article_ordering_indexes
+CREATE INDEX idx_articles_user_id ON Article (UserId);
+CREATE INDEX idx_articles_updated_at ON Article (UpdatedAt, ArticleId);
+
+-- from 000014_create_article_search_table, which explains them
+CREATE TRIGGER trg_article_search_insert AFTER INSERT ON Article
+BEGIN
+    INSERT INTO ArticleSearch (rowid, Title, Description, Body)
+    VALUES (new.ArticleId, new.Title, new.Description, new.Body);
+END;
+
+CREATE TRIGGER trg_article_search_delete AFTER DELETE ON Article
+BEGIN
+    INSERT INTO ArticleSearch (ArticleSearch, rowid, Title, Description, Body)
+    VALUES ('delete', old.ArticleId, old.Title, old.Description, old.Body);
+END;
+
+CREATE TRIGGER trg_article_search_update AFTER UPDATE OF Title, Description, Body ON Article
+BEGIN
+    INSERT INTO ArticleSearch (ArticleSearch, rowid, Title, Description, Body)
+    VALUES ('delete', old.ArticleId, old.Title, old.Description, old.Body);
+    INSERT INTO ArticleSearch (rowid, Title, Description, Body)
+    VALUES (new.ArticleId, new.Title, new.Description, new.Body);
+END;
+
+-- from 000015_create_article_slug_history_table, which explains them
+CREATE TRIGGER trg_article_slug_history_update AFTER UPDATE OF Slug ON Article
+WHEN old.Slug <> new.Slug
+BEGIN
+    INSERT INTO ArticleSlugHistory (Slug, ArticleId, CreatedAt) VALUES (old.Slug, new.ArticleId, new.UpdatedAt);
+    DELETE FROM ArticleSlugHistory WHERE Slug = new.Slug;
+END;
+
+CREATE TRIGGER trg_article_slug_history_reserve_insert BEFORE INSERT ON Article
+WHEN EXISTS (SELECT 1 FROM ArticleSlugHistory WHERE Slug = new.Slug)
+BEGIN
+    SELECT RAISE(ABORT, 'UNIQUE constraint failed: Article.Slug');
+END;
+
+CREATE TRIGGER trg_article_slug_history_reserve_update BEFORE UPDATE OF Slug ON Article
+WHEN EXISTS (SELECT 1 FROM ArticleSlugHistory WHERE Slug = new.Slug AND ArticleId <> new.ArticleId)
+BEGIN
+    SELECT RAISE(ABORT, 'UNIQUE constraint failed: Article.Slug');
+END;
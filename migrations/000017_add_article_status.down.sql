@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+DROP INDEX IF EXISTS idx_articles_status_publish_at;
+ALTER TABLE Article DROP COLUMN PublishAt;
+ALTER TABLE Article DROP COLUMN Status;
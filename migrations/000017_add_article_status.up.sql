@@ -0,0 +1,10 @@
+PRAGMA foreign_keys = ON;
+
+-- existing articles were all public as soon as they were created
+ALTER TABLE Article ADD COLUMN Status TEXT NOT NULL DEFAULT 'published' CHECK (Status IN ('draft', 'scheduled', 'published'));
+-- when a scheduled article is due to be published, or when a published one was
+ALTER TABLE Article ADD COLUMN PublishAt TEXT;
+
+UPDATE Article SET PublishAt = CreatedAt;
+
+CREATE INDEX idx_articles_status_publish_at ON Article (Status, PublishAt);
@@ -0,0 +1,4 @@
+PRAGMA foreign_keys = ON;
+
+DROP TRIGGER IF EXISTS trg_article_revision_immutable;
+DROP TABLE IF EXISTS ArticleRevision;
@@ -0,0 +1,26 @@
+PRAGMA foreign_keys = ON;
+
+-- the title, description and body of an article after each change to them
+CREATE TABLE ArticleRevision (
+    RevisionId INTEGER NOT NULL PRIMARY KEY,
+    ArticleId INTEGER NOT NULL,
+    -- counts up from 1 for each article
+    Number INTEGER NOT NULL,
+    Title TEXT NOT NULL,
+    Description TEXT NOT NULL,
+    Body TEXT NOT NULL,
+    CreatedAt TEXT NOT NULL,
+    -- the number of the revision this one was restored from
+    RestoredFrom INTEGER,
+    UNIQUE (ArticleId, Number),
+    FOREIGN KEY (ArticleId) REFERENCES Article (ArticleId) ON DELETE CASCADE
+);
+
+CREATE TRIGGER trg_article_revision_immutable BEFORE UPDATE ON ArticleRevision
+BEGIN
+    SELECT RAISE(ABORT, 'article revisions can not be changed');
+END;
+
+-- articles that already exist start with their current content as the first revision
+INSERT INTO ArticleRevision (ArticleId, Number, Title, Description, Body, CreatedAt)
+SELECT ArticleId, 1, Title, Description, Body, UpdatedAt FROM Article;
@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE Article DROP COLUMN Excerpt;
+ALTER TABLE Article DROP COLUMN ReadingTimeMinutes;
+ALTER TABLE Article DROP COLUMN WordCount;
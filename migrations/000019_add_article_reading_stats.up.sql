@@ -0,0 +1,8 @@
+PRAGMA foreign_keys = ON;
+
+-- worked out from the Markdown body when an article is saved, a NULL word count marks an
+-- article saved before these were added, which is filled in when the application starts
+ALTER TABLE Article ADD COLUMN WordCount INTEGER;
+ALTER TABLE Article ADD COLUMN ReadingTimeMinutes INTEGER NOT NULL DEFAULT 0;
+-- the start of the body, only for articles whose description is too short to stand on its own
+ALTER TABLE Article ADD COLUMN Excerpt TEXT;
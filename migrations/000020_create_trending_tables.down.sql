@@ -0,0 +1,11 @@
+PRAGMA foreign_keys = ON;
+
+DROP INDEX IF EXISTS idx_article_scores_score;
+DROP TABLE IF EXISTS ArticleScore;
+
+DROP INDEX IF EXISTS idx_article_views_viewed_at;
+DROP TABLE IF EXISTS ArticleView;
+
+DROP INDEX IF EXISTS idx_comments_created_at;
+DROP INDEX IF EXISTS idx_article_favorites_created_at;
+ALTER TABLE ArticleFavorite DROP COLUMN CreatedAt;
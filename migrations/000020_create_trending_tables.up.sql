@@ -0,0 +1,29 @@
+PRAGMA foreign_keys = ON;
+
+-- favorites made before this have no time, so they never count towards an article trending
+ALTER TABLE ArticleFavorite ADD COLUMN CreatedAt TEXT;
+
+CREATE INDEX idx_article_favorites_created_at ON ArticleFavorite (CreatedAt);
+CREATE INDEX idx_comments_created_at ON Comment (CreatedAt);
+
+-- at most one view is recorded per viewer an hour, ViewedAt is the start of the hour. The viewer
+-- is the user id for signed in users and a hash of the address for everyone else.
+CREATE TABLE ArticleView (
+    ArticleViewId INTEGER NOT NULL PRIMARY KEY,
+    ArticleId INTEGER NOT NULL,
+    Viewer TEXT NOT NULL,
+    ViewedAt TEXT NOT NULL,
+    UNIQUE (ArticleId, Viewer, ViewedAt),
+    FOREIGN KEY (ArticleId) REFERENCES Article (ArticleId) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_article_views_viewed_at ON ArticleView (ViewedAt);
+
+-- recomputed from the views, favorites and comments in the trending window by a background job
+CREATE TABLE ArticleScore (
+    ArticleId INTEGER NOT NULL PRIMARY KEY,
+    Score REAL NOT NULL,
+    FOREIGN KEY (ArticleId) REFERENCES Article (ArticleId) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_article_scores_score ON ArticleScore (Score DESC, ArticleId DESC);
@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+-- the suffixed slugs are left as they are, going back to a reserved slug would only make the
+-- article unreachable again
+SELECT 1;
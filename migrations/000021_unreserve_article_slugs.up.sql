@@ -0,0 +1,10 @@
+PRAGMA foreign_keys = ON;
+
+-- articles saved before feed, search and trending were reserved could have one of them as their
+-- slug, which routes to the list instead of the article. They get a suffix the same way
+-- withSlugSuffix would give them one, and the version goes up since the representation changed.
+-- The old slug is kept in ArticleSlugHistory by its trigger, which stops another article taking it.
+UPDATE Article
+SET Slug = Slug || '-' || lower(hex(randomblob(3))),
+    Version = Version + 1
+WHERE Slug IN ('feed', 'search', 'trending');
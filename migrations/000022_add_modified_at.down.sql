@@ -0,0 +1,19 @@
+PRAGMA foreign_keys = ON;
+
+DROP TRIGGER IF EXISTS trg_tags_modified_user_deleted;
+DROP TRIGGER IF EXISTS trg_tags_modified_article_status;
+DROP TRIGGER IF EXISTS trg_follower_modified_delete;
+DROP TRIGGER IF EXISTS trg_follower_modified_insert;
+DROP TRIGGER IF EXISTS trg_user_modified_update;
+DROP TRIGGER IF EXISTS trg_user_modified_insert;
+DROP TRIGGER IF EXISTS trg_article_favorite_modified_delete;
+DROP TRIGGER IF EXISTS trg_article_favorite_modified_insert;
+DROP TRIGGER IF EXISTS trg_article_tag_modified_delete;
+DROP TRIGGER IF EXISTS trg_article_tag_modified_insert;
+DROP TRIGGER IF EXISTS trg_article_modified_update;
+DROP TRIGGER IF EXISTS trg_article_modified_insert;
+
+DROP TABLE IF EXISTS TagsModified;
+
+ALTER TABLE User DROP COLUMN ModifiedAt;
+ALTER TABLE Article DROP COLUMN ModifiedAt;
@@ -0,0 +1,86 @@
+PRAGMA foreign_keys = ON;
+
+-- when the representation of an article, a profile or the tag list last changed, for Last-Modified.
+-- Unfavoriting, unfollowing and removing tags leave no row behind to take a date from, so these are
+-- kept up to date by triggers. Every date is written by strftime so that they all have milliseconds.
+ALTER TABLE Article ADD COLUMN ModifiedAt TEXT;
+ALTER TABLE User ADD COLUMN ModifiedAt TEXT;
+
+CREATE TABLE TagsModified (
+    TagsModifiedId INTEGER NOT NULL PRIMARY KEY CHECK (TagsModifiedId = 1),
+    ModifiedAt TEXT NOT NULL
+);
+
+UPDATE Article SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+UPDATE User SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+INSERT INTO TagsModified (TagsModifiedId, ModifiedAt) VALUES (1, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'));
+
+-- an article's own columns, its tags and its favorites
+CREATE TRIGGER trg_article_modified_insert AFTER INSERT ON Article
+BEGIN
+    UPDATE Article SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE ArticleId = new.ArticleId;
+END;
+
+CREATE TRIGGER trg_article_modified_update AFTER UPDATE ON Article
+WHEN new.ModifiedAt IS old.ModifiedAt
+BEGIN
+    UPDATE Article SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE ArticleId = new.ArticleId;
+END;
+
+CREATE TRIGGER trg_article_tag_modified_insert AFTER INSERT ON ArticleTag
+BEGIN
+    UPDATE Article SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE ArticleId = new.ArticleId;
+    UPDATE TagsModified SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+END;
+
+CREATE TRIGGER trg_article_tag_modified_delete AFTER DELETE ON ArticleTag
+BEGIN
+    UPDATE Article SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE ArticleId = old.ArticleId;
+    UPDATE TagsModified SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+END;
+
+CREATE TRIGGER trg_article_favorite_modified_insert AFTER INSERT ON ArticleFavorite
+BEGIN
+    UPDATE Article SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE ArticleId = new.ArticleId;
+END;
+
+CREATE TRIGGER trg_article_favorite_modified_delete AFTER DELETE ON ArticleFavorite
+BEGIN
+    UPDATE Article SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE ArticleId = old.ArticleId;
+END;
+
+-- a user's own columns and whoever follows them, which is all a profile shows
+CREATE TRIGGER trg_user_modified_insert AFTER INSERT ON User
+BEGIN
+    UPDATE User SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE UserId = new.UserId;
+END;
+
+CREATE TRIGGER trg_user_modified_update AFTER UPDATE ON User
+WHEN new.ModifiedAt IS old.ModifiedAt
+BEGIN
+    UPDATE User SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE UserId = new.UserId;
+END;
+
+CREATE TRIGGER trg_follower_modified_insert AFTER INSERT ON Follower
+BEGIN
+    UPDATE User SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE UserId = new.FollowUserId;
+END;
+
+CREATE TRIGGER trg_follower_modified_delete AFTER DELETE ON Follower
+BEGIN
+    UPDATE User SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE UserId = old.FollowUserId;
+END;
+
+-- the tag list only has tags of published articles by users that haven't been deleted, tags
+-- being added to or removed from articles are covered above
+CREATE TRIGGER trg_tags_modified_article_status AFTER UPDATE OF Status ON Article
+WHEN new.Status <> old.Status
+BEGIN
+    UPDATE TagsModified SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+END;
+
+CREATE TRIGGER trg_tags_modified_user_deleted AFTER UPDATE OF DeletedAt ON User
+WHEN new.DeletedAt IS NOT old.DeletedAt
+BEGIN
+    UPDATE TagsModified SET ModifiedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+END;
@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+DROP INDEX IF EXISTS idx_articles_published_at;
+DROP INDEX IF EXISTS idx_articles_updated_at;
+CREATE INDEX idx_articles_updated_at ON Article (UpdatedAt, ArticleId);
@@ -0,0 +1,7 @@
+PRAGMA foreign_keys = ON;
+
+-- articles are sorted on these julian days rather than the text they are stored as, which only
+-- use an index on the same expression
+DROP INDEX IF EXISTS idx_articles_updated_at;
+CREATE INDEX idx_articles_updated_at ON Article (julianday(UpdatedAt), ArticleId);
+CREATE INDEX idx_articles_published_at ON Article (julianday(COALESCE(PublishAt, CreatedAt)), ArticleId);
@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+-- tokens carry the version they were issued under, bumping it revokes every
+-- token issued before, e.g. when an account is deleted and later restored
+ALTER TABLE User ADD COLUMN TokenVersion INTEGER NOT NULL DEFAULT 0;